@@ -0,0 +1,184 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// aliasPaths lists where the alias file is looked for when -config isn't
+// given, in order of preference.
+func aliasPaths() []string {
+	var paths []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "wol", "hosts"), filepath.Join(dir, "wol", "hosts.yaml"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".wol.conf"))
+	}
+	return paths
+}
+
+// loadAliases reads the host aliases from path, or from the first of the
+// default aliasPaths that exists when path is empty. Having no alias file at
+// all is not an error.
+func loadAliases(path string) (map[string]target, error) {
+	if path != "" {
+		return readAliasFile(path)
+	}
+
+	for _, path := range aliasPaths() {
+		aliases, err := readAliasFile(path)
+		if isMissing(err) {
+			continue
+		}
+		return aliases, err
+	}
+	return map[string]target{}, nil
+}
+
+// isMissing reports whether err is from opening an alias file which doesn't
+// exist, including one below a path which isn't a directory, e.g. when
+// ~/.config is a regular file.
+func isMissing(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR)
+}
+
+// readAliasFile reads the host aliases in the file at path, a YAML inventory
+// if it is named *.yaml or *.yml.
+func readAliasFile(path string) (map[string]target, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if isInventoryFile(path) {
+		return parseInventory(f, path)
+	}
+	return parseAliases(f, path)
+}
+
+// parseAliases reads one host alias per line from r, ignoring blank lines and
+// `#` comments. Each line holds a name, a MAC address, an optional broadcast IP
+// (which may carry a port) and optional key=value settings:
+//
+//	nas    18:18:18:18:18:18  192.168.1.255  ip=192.168.1.10
+//	lab1   18:18:18:18:18:1a  10.0.5.255:7
+//	laptop 18-18-18-18-18-19  port=7 tags=lab,laptops
+func parseAliases(r io.Reader, name string) (map[string]target, error) {
+	aliases := map[string]target{}
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected NAME MAC_ADDRESS [BROADCAST_IP] [KEY=VALUE...]", name, lineNo)
+		}
+		if _, err := wol.MagicPacketNew(fields[1]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, lineNo, err)
+		}
+		if _, ok := aliases[fields[0]]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate alias %s", name, lineNo, fields[0])
+		}
+
+		t := target{
+			Name: fields[0],
+			MAC:  fields[1],
+		}
+		for _, field := range fields[2:] {
+			if err := t.setAliasField(field); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", name, lineNo, err)
+			}
+		}
+		aliases[t.Name] = t
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
+
+// setAliasField applies one of the optional fields following the MAC address
+// in an alias file entry.
+func (t *target) setAliasField(field string) error {
+	key, value, ok := strings.Cut(field, "=")
+	switch {
+	case !ok && t.Broadcast == "":
+		t.Broadcast = field
+		if host, port, err := net.SplitHostPort(field); err == nil {
+			t.Broadcast = host
+			return t.setPort(port)
+		}
+	case key == "ip":
+		t.IP = value
+	case key == "port":
+		return t.setPort(value)
+	case key == "tags":
+		for _, tag := range strings.Split(value, ",") {
+			if tag == "" {
+				return fmt.Errorf("empty tag in %q", field)
+			}
+			t.Tags = append(t.Tags, tag)
+		}
+	default:
+		return fmt.Errorf("unexpected field %q", field)
+	}
+	return nil
+}
+
+// setPort sets the UDP port the alias' magic packets are sent to.
+func (t *target) setPort(value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("port %q is not a number in the range 1-65535", value)
+	}
+	t.Port = port
+	return nil
+}
+
+// taggedAliases returns the aliases tagged with tag, sorted by name so that a
+// group is always woken in the same order.
+func taggedAliases(tag string, aliases map[string]target) ([]target, error) {
+	var tagged []target
+	for _, t := range aliases {
+		for _, aliasTag := range t.Tags {
+			if aliasTag == tag {
+				tagged = append(tagged, t)
+				break
+			}
+		}
+	}
+	if len(tagged) == 0 {
+		return nil, fmt.Errorf("no host alias is tagged %s", tag)
+	}
+
+	sort.Slice(tagged, func(i, j int) bool {
+		return tagged[i].Name < tagged[j].Name
+	})
+	return tagged, nil
+}
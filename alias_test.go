@@ -0,0 +1,32 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestLoadAliasesMissing(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	// No alias file at all.
+	aliases, err := loadAliases("")
+	if err != nil || len(aliases) != 0 {
+		t.Errorf("loadAliases without an alias file = %v, %v, want no aliases", aliases, err)
+	}
+
+	// A regular file where the config directory would be.
+	if err := os.WriteFile(filepath.Join(home, ".config"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	aliases, err = loadAliases("")
+	if err != nil || len(aliases) != 0 {
+		t.Errorf("loadAliases with ~/.config a file = %v, %v, want no aliases", aliases, err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"net"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// broadcastForCIDR returns the directed broadcast address of an IPv4 subnet in
+// CIDR notation, e.g. "192.168.1.0/24" -> "192.168.1.255".
+func broadcastForCIDR(cidr string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+
+	ip := ipNet.IP.To4()
+	if ip == nil || len(ipNet.Mask) != net.IPv4len {
+		return "", fmt.Errorf("%s is not an IPv4 subnet, IPv6 has no broadcast address", cidr)
+	}
+
+	// Point-to-point (/31) and host (/32) routes have no broadcast address.
+	bcast := wol.DirectedBroadcast(ip, ipNet.Mask)
+	if bcast == nil {
+		return "", fmt.Errorf("%s has no directed broadcast address (prefix must be /30 or shorter)", cidr)
+	}
+	return bcast.String(), nil
+}
+
+// isBroadcastIP reports whether ip reaches every host on a segment: the limited
+// broadcast address, a multicast group or the directed broadcast address of a
+// subnet. Subnets the local machine isn't on can't be checked, so beyond those
+// an address ending in .255 is given the benefit of the doubt.
+func isBroadcastIP(ip net.IP) bool {
+	if ip.Equal(net.IPv4bcast) || ip.IsMulticast() {
+		return true
+	}
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return false
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ip4[3] == 0xFF
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil || !ipNet.Contains(ip4) {
+			continue
+		}
+		bcast, err := broadcastForCIDR(ipNet.String())
+		return err == nil && ip4.Equal(net.ParseIP(bcast))
+	}
+	return ip4[3] == 0xFF
+}
@@ -0,0 +1,174 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// command is a subcommand, e.g. `wol listen`, with a flag set of its own.
+// Waking is the default command, so that `wol MAC_ADDRESS` is shorthand for
+// `wol wake MAC_ADDRESS`.
+type command struct {
+	synopsis string
+	flags    *flag.FlagSet
+	run      func(args []string) error
+}
+
+// commands lists the subcommands by name. It's filled in by init, as the
+// completion command reads it back.
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"wake": {
+			synopsis: "wol wake [FLAGS] MAC_ADDRESS|ALIAS|@TAG... [BROADCAST_IP]",
+			flags:    flag.CommandLine,
+			run:      runWake,
+		},
+		"listen": {
+			synopsis: "wol listen [FLAGS]",
+			flags:    newFlagSet("listen", outputFlags, listenFlags),
+			run:      noArgs(listenCmd),
+		},
+		"list": {
+			synopsis: "wol list [FLAGS]",
+			flags:    newFlagSet("list", outputFlags, configFlag),
+			run:      listCmd,
+		},
+		"replay": {
+			synopsis: "wol replay [FLAGS] FILE [BROADCAST_IP]",
+			flags:    newFlagSet("replay", outputFlags, replayFlags),
+			run:      replayCmd,
+		},
+		"version": {
+			synopsis: "wol version",
+			flags:    newFlagSet("version"),
+			run:      noArgs(printVersion),
+		},
+		"completion": {
+			synopsis: "wol completion bash|zsh|fish",
+			flags:    newFlagSet("completion", configFlag),
+			run:      completionCmd,
+		},
+	}
+}
+
+// activeFlags is the flag set of the command being run.
+var activeFlags = flag.CommandLine
+
+// newFlagSet returns a flag set for the named subcommand, with the flags added
+// by each of register. Flags shared between subcommands set the same cliFlags
+// field whichever subcommand they are given to.
+func newFlagSet(name string, register ...func(fs *flag.FlagSet)) *flag.FlagSet {
+	fs := flag.NewFlagSet("wol "+name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	for _, r := range register {
+		r(fs)
+	}
+	return fs
+}
+
+// outputFlags adds the flags controlling what is printed.
+func outputFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&cliFlags.JSON, "json", false, "print the output as JSON")
+	fs.BoolVar(&cliFlags.Quiet, "q", false, "shorthand for -quiet")
+	fs.BoolVar(&cliFlags.Quiet, "quiet", false, "suppress informational output, only print errors to stderr")
+	fs.Var(&cliFlags.Verbose, "v", "log each step to stderr")
+}
+
+// listenFlags adds the flags of `wol listen`.
+func listenFlags(fs *flag.FlagSet) {
+	fs.IntVar(&cliFlags.Port, "port", wol.DefaultPort, "UDP port to listen on")
+	fs.StringVar(&cliFlags.Group, "group", "", "also receive the magic packets sent to this IPv4 multicast group, e.g. 239.255.0.9")
+	fs.StringVar(&cliFlags.BroadcastInterface, "interface", "", "network interface to join the -group on, by name (default picked by the OS)")
+}
+
+// configFlag adds the -config flag picking the host alias file.
+func configFlag(fs *flag.FlagSet) {
+	fs.StringVar(&cliFlags.Config, "config", "", "host alias file, or YAML inventory if named *.yaml (default ~/.config/wol/hosts, hosts.yaml or ~/.wol.conf)")
+}
+
+// noArgs adapts a command which takes no positional arguments.
+func noArgs(run func() error) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("unexpected arguments %q", args)
+		}
+		return run()
+	}
+}
+
+// printVersion prints the version of the running build.
+func printVersion() error {
+	fmt.Println(versionString())
+	return nil
+}
+
+// runCommand parses the flags of cmd from args and runs it, exiting with the
+// status the outcome calls for.
+func runCommand(cmd *command, args []string) {
+	activeFlags = cmd.flags
+	rest, err := parseArgs(cmd.flags, args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		cmd.usage(os.Stdout)
+		os.Exit(exitOK)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error: %s\n\n", err)
+		cmd.usage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+	exit(cmd.run(rest))
+}
+
+// usage prints the help of cmd, including its flags, to w.
+func (cmd *command) usage(w io.Writer) {
+	if cmd.flags == flag.CommandLine {
+		usage(w)
+		return
+	}
+
+	fmt.Fprintf(w, "Usage: %s\n", cmd.synopsis)
+	hasFlags := false
+	cmd.flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if !hasFlags {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	cmd.flags.SetOutput(w)
+	cmd.flags.PrintDefaults()
+	cmd.flags.SetOutput(io.Discard)
+}
+
+// runWake runs the default command, waking the targets in args. The -version
+// and -listen flags and the trailing list and completion arguments predate the
+// subcommands, and still work.
+func runWake(args []string) error {
+	switch {
+	case cliFlags.Version:
+		return printVersion()
+	case cliFlags.Listen:
+		return listenCmd()
+	case len(args) == 0 && cliFlags.File == "" && cliFlags.ARP == "" && cliFlags.Self == "" && !stdinIsPiped():
+		usage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+
+	switch {
+	case len(args) > 0 && args[0] == "completion":
+		return completionCmd(args[1:])
+	case len(args) > 0 && args[0] == "list":
+		return listCmd(args[1:])
+	}
+	return wakeCmd(args)
+}
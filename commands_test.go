@@ -0,0 +1,35 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestRunWakeSelf(t *testing.T) {
+	// Without any positional arguments, -self alone names the target rather
+	// than printing the usage. A terminal-like stdin rules out reading MAC
+	// addresses from it instead.
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	defer func(saved *os.File) { os.Stdin = saved }(os.Stdin)
+	os.Stdin = stdin
+	t.Setenv("HOME", t.TempDir())
+
+	defer func(self string, dryRun bool) {
+		cliFlags.Self, cliFlags.DryRun = self, dryRun
+	}(cliFlags.Self, cliFlags.DryRun)
+	cliFlags.Self, cliFlags.DryRun = "no-such-interface0", true
+
+	err = runWake(nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "-self no-such-interface0") {
+		t.Errorf("runWake with -self = %v, want the -self interface lookup to fail", err)
+	}
+}
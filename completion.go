@@ -0,0 +1,241 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// completionCmd prints a shell completion script for the named shell. The
+// scripts complete the subcommands, the flags of each and, by running `wol
+// completion aliases`, the host aliases of the alias file in use when tab is
+// pressed.
+func completionCmd(args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected completion bash|zsh|fish")
+	}
+
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(os.Stdout)
+	case "zsh":
+		writeZshCompletion(os.Stdout)
+	case "fish":
+		writeFishCompletion(os.Stdout)
+	case "aliases":
+		aliases, err := loadAliases(cliFlags.Config)
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(aliases))
+		tags := map[string]bool{}
+		for name, t := range aliases {
+			names = append(names, name)
+			for _, tag := range t.Tags {
+				if !tags[tag] {
+					tags[tag] = true
+					names = append(names, "@"+tag)
+				}
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	default:
+		return fmt.Errorf("unsupported shell %q (expected bash, zsh or fish)", args[0])
+	}
+	return nil
+}
+
+// filesArg in completedArgs has the shells complete file names.
+const filesArg = "<files>"
+
+// completedArgs is what the completion scripts offer for the positional
+// arguments of each subcommand besides waking, which is offered the host
+// aliases: a list of words, filesArg, or nothing if it's missing.
+var completedArgs = map[string]string{
+	"replay":     filesArg,
+	"completion": "bash zsh fish",
+}
+
+// commandNames returns the subcommand names in order.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flagNames returns the name of every flag in fs prefixed with a dash, e.g.
+// "-port".
+func flagNames(fs *flag.FlagSet) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	return names
+}
+
+// isBoolFlag reports whether f is set by its name alone, without a value.
+func isBoolFlag(f *flag.Flag) bool {
+	bf, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && bf.IsBoolFlag()
+}
+
+func writeBashCompletion(w io.Writer) {
+	var cases strings.Builder
+	for _, name := range commandNames() {
+		if name == "wake" {
+			continue
+		}
+		fmt.Fprintf(&cases, "\t%s)\n\t\tflags=%q\n", name, strings.Join(flagNames(commands[name].flags), " "))
+		switch args := completedArgs[name]; args {
+		case "":
+		case filesArg:
+			fmt.Fprintf(&cases, "\t\tfiles=1\n")
+		default:
+			fmt.Fprintf(&cases, "\t\twords=%q\n", args)
+		}
+		fmt.Fprintf(&cases, "\t\t;;\n")
+	}
+
+	fmt.Fprintf(w, `# bash completion for wol, load with: source <(wol completion bash)
+_wol() {
+	local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+	local cmd= flags= words= files=
+	((COMP_CWORD > 1)) && cmd=${COMP_WORDS[1]}
+	case $prev in
+	-file|--file|-config|--config)
+		COMPREPLY=($(compgen -f -- "$cur"))
+		return
+		;;
+	esac
+	case $cmd in
+%s	*)
+		flags=%q
+		words=$(wol completion aliases 2>/dev/null)
+		;;
+	esac
+	if [[ $cur == -* ]]; then
+		COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+		return
+	fi
+	if [[ -n $files ]]; then
+		COMPREPLY=($(compgen -f -- "$cur"))
+		return
+	fi
+	((COMP_CWORD == 1)) && words="%s $words"
+	COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -F _wol wol
+`, cases.String(), strings.Join(flagNames(flag.CommandLine), " "), strings.Join(commandNames(), " "))
+}
+
+func writeZshCompletion(w io.Writer) {
+	var cases strings.Builder
+	for _, name := range commandNames() {
+		if name == "wake" {
+			continue
+		}
+		fmt.Fprintf(&cases, "\t%s)\n\t\tflags=(%s)\n", name, strings.Join(flagNames(commands[name].flags), " "))
+		switch args := completedArgs[name]; args {
+		case "":
+		case filesArg:
+			fmt.Fprintf(&cases, "\t\tfiles=1\n")
+		default:
+			fmt.Fprintf(&cases, "\t\targs=(%s)\n", args)
+		}
+		fmt.Fprintf(&cases, "\t\t;;\n")
+	}
+
+	fmt.Fprintf(w, `#compdef wol
+# zsh completion for wol, load with: source <(wol completion zsh)
+_wol() {
+	local -a flags args
+	local cmd= files=
+	((CURRENT > 2)) && cmd=${words[2]}
+	case ${words[CURRENT-1]} in
+	-file|--file|-config|--config)
+		_files
+		return
+		;;
+	esac
+	case $cmd in
+%s	*)
+		flags=(%s)
+		args=(${(f)"$(wol completion aliases 2>/dev/null)"})
+		;;
+	esac
+	if [[ $PREFIX == -* ]]; then
+		compadd -a flags
+		return
+	fi
+	if [[ -n $files ]]; then
+		_files
+		return
+	fi
+	((CURRENT == 2)) && args+=(%s)
+	compadd -a args
+}
+compdef _wol wol
+`, cases.String(), strings.Join(flagNames(flag.CommandLine), " "), strings.Join(commandNames(), " "))
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# fish completion for wol, load with: wol completion fish | source")
+	names := commandNames()
+	for _, name := range names {
+		fmt.Fprintf(w, "complete -c wol -n __fish_use_subcommand -f -a %s -d %s\n", name, fishQuote(commands[name].synopsis))
+	}
+
+	// Waking is the default command, so its flags and the host aliases are
+	// offered unless another subcommand was given.
+	var others []string
+	for _, name := range names {
+		if name != "wake" {
+			others = append(others, name)
+		}
+	}
+	waking := fishQuote("not __fish_seen_subcommand_from " + strings.Join(others, " "))
+	for _, name := range names {
+		cond := fishQuote("__fish_seen_subcommand_from " + name)
+		if name == "wake" {
+			cond = waking
+		}
+		commands[name].flags.VisitAll(func(f *flag.Flag) {
+			requires := " -r"
+			if isBoolFlag(f) {
+				requires = ""
+			}
+			fmt.Fprintf(w, "complete -c wol -n %s -o %s%s -d %s\n", cond, f.Name, requires, fishQuote(f.Usage))
+		})
+		switch args := completedArgs[name]; {
+		case name == "wake":
+			fmt.Fprintf(w, "complete -c wol -n %s -f -a '(wol completion aliases 2>/dev/null)'\n", cond)
+		case args == filesArg:
+			fmt.Fprintf(w, "complete -c wol -n %s -F\n", cond)
+		case args != "":
+			fmt.Fprintf(w, "complete -c wol -n %s -f -a %s\n", cond, fishQuote(args))
+		default:
+			fmt.Fprintf(w, "complete -c wol -n %s -f\n", cond)
+		}
+	}
+}
+
+// fishQuote single quotes s for a fish script.
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
@@ -0,0 +1,44 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestCompletionScripts(t *testing.T) {
+	for shell, write := range map[string]func(w io.Writer){
+		"bash": writeBashCompletion,
+		"zsh":  writeZshCompletion,
+		"fish": writeFishCompletion,
+	} {
+		var script strings.Builder
+		write(&script)
+		for _, name := range commandNames() {
+			if !strings.Contains(script.String(), name) {
+				t.Errorf("%s completion doesn't offer the %s subcommand", shell, name)
+			}
+			commands[name].flags.VisitAll(func(f *flag.Flag) {
+				want := "-" + f.Name
+				if shell == "fish" {
+					want = "-o " + f.Name + " "
+				}
+				if !strings.Contains(script.String(), want) {
+					t.Errorf("%s completion doesn't offer -%s of %s", shell, f.Name, name)
+				}
+			})
+		}
+	}
+
+	// Flags of a subcommand are offered only with it.
+	var script strings.Builder
+	writeBashCompletion(&script)
+	if !strings.Contains(script.String(), "\tlisten)\n\t\tflags=\"-group ") {
+		t.Errorf("bash completion doesn't offer the flags of listen apart:\n%s", script.String())
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// daemonCmd resends the magic packet for t every -daemon-interval until
+// interrupted, for devices which only listen during a brief polling window.
+// The connection is opened once and reused for every send.
+func daemonCmd(t target, broadcastIP string, opts []wol.Option) error {
+	if cliFlags.DaemonInterval <= 0 {
+		return fmt.Errorf("-daemon-interval %s must be positive", cliFlags.DaemonInterval)
+	}
+
+	// Catch a bad -password up front rather than on every send.
+	if _, err := buildPacket(t); err != nil {
+		return err
+	}
+
+	broadcastIP = t.destination(broadcastIP)
+	bcastAddr := net.JoinHostPort(broadcastIP, strconv.Itoa(t.destinationPort()))
+	if t.Interface != "" {
+		opts = append(opts[:len(opts):len(opts)], wol.WithInterface(t.Interface))
+	}
+	waker, err := wol.NewWaker(bcastAddr, opts...)
+	if err != nil {
+		return explainNetError(err)
+	}
+	defer waker.Close()
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ticker := time.NewTicker(cliFlags.DaemonInterval)
+	defer ticker.Stop()
+
+	infof("Sending a magic packet to %s via %s every %s, press Ctrl+C to stop\n", t, bcastAddr, cliFlags.DaemonInterval)
+	for sent := 1; ; sent++ {
+		if err := waker.Wake(t.MAC); err != nil {
+			warnf("%s: send %d failed: %s\n", time.Now().Format("15:04:05"), sent, explainNetError(err))
+		} else {
+			infof("%s: sent magic packet %d to %s\n", time.Now().Format("15:04:05"), sent, t)
+		}
+
+		select {
+		case <-ctx.Done():
+			infof("Stopped after %d sends\n", sent)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
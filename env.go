@@ -0,0 +1,46 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Environment variables which replace the built in defaults, for deployments
+// such as containers where the right values differ from host to host. Command
+// line arguments always take precedence over them.
+const (
+	envBroadcast = "WOL_BROADCAST"
+	envPort      = "WOL_PORT"
+)
+
+// applyEnvDefaults sets the flags which weren't given on the command line from
+// their environment variables.
+func applyEnvDefaults() error {
+	if value, ok := os.LookupEnv(envPort); ok && value != "" && !flagGiven("port") {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s=%s is not a port number", envPort, value)
+		}
+		cliFlags.Port = port
+	}
+	return nil
+}
+
+// defaultBroadcast returns the address magic packets are sent to when neither
+// the command line nor the host alias name one.
+func defaultBroadcast() string {
+	if cliFlags.IPv6 {
+		return wol.DefaultMulticast6
+	}
+	if value := os.Getenv(envBroadcast); value != "" {
+		return value
+	}
+	return wol.DefaultBroadcast
+}
@@ -0,0 +1,63 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Exit codes, so that scripts can tell how a run went.
+const (
+	exitOK     = 0 // every target was woken
+	exitFailed = 1 // at least one target could not be woken
+	exitUsage  = 2 // the command line could not be parsed, or its flags conflict
+	exitNotUp  = 3 // every magic packet was sent, but the -wait host never came up
+	exitSignal = 4 // SIGINT or SIGTERM stopped the batch part way through
+)
+
+// exitCode maps the outcome of a command onto the exit code policy. A batch is
+// always attempted in full, so failing a single target is enough for
+// exitFailed.
+func exitCode(err error) int {
+	var notUp *wol.NotUpError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &notUp):
+		return exitNotUp
+	case errors.Is(err, errInterrupted):
+		return exitSignal
+	case errors.As(err, new(*usageError)):
+		return exitUsage
+	}
+	return exitFailed
+}
+
+// usageError is a mistake on the command line found once it has been parsed,
+// such as two flags which can't be combined.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// usageErrorf returns a usageError, exiting with exitUsage.
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{fmt.Sprintf(format, args...)}
+}
+
+// exit reports err, if any, and exits with its exitCode.
+func exit(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fatal error: %s\n", err.Error())
+	}
+	os.Exit(exitCode(err))
+}
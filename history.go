@@ -0,0 +1,70 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/user"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// historyMaxSize is the size beyond which the -history file is rotated to
+// PATH.1 before appending to it, replacing any earlier PATH.1.
+const historyMaxSize = 1 << 20
+
+// historyEntry is a line of the -history file, recording who woke what and
+// how it went.
+type historyEntry struct {
+	Time time.Time `json:"time"`
+	User string    `json:"user,omitempty"`
+	result
+}
+
+// appendHistory appends a line for every magic packet send in results to the
+// -history file at path, rotating the file first once it has grown beyond
+// historyMaxSize. Dry runs and input lines which didn't parse send nothing and
+// aren't recorded.
+func appendHistory(path string, results []result) error {
+	now, who := time.Now(), currentUser()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, res := range results {
+		if res.target.MAC == "" || res.DryRun {
+			continue
+		}
+		if err := enc.Encode(historyEntry{Time: now, User: who, result: res}); err != nil {
+			return err
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Size() >= historyMaxSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	// A single write keeps the lines of concurrent runs from interleaving.
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// currentUser names the user running wol, for the -history file.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,111 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// historyLines returns the entries of the -history file at path.
+func historyLines(t *testing.T, path string) []historyEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("%s: bad line %q: %s", path, scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestAppendHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	woken := result{target: target{MAC: "18:18:18:18:18:18"}, MAC: "18:18:18:18:18:18", Success: true}
+	failed := result{target: target{MAC: "18:18:18:18:18:19"}, MAC: "18:18:18:18:18:19", Error: "no route"}
+	dryRun := result{target: target{MAC: "18:18:18:18:18:1a"}, MAC: "18:18:18:18:18:1a", DryRun: true, Success: true}
+	badLine := result{Error: "hosts.txt:3: not a MAC address"}
+
+	if err := appendHistory(path, []result{woken, dryRun, badLine}); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendHistory(path, []result{failed}); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendHistory(path, []result{dryRun}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := historyLines(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("history has %d entries, want 2: %+v", len(entries), entries)
+	}
+	if entries[0].MAC != woken.MAC || !entries[0].Success || entries[0].Time.IsZero() {
+		t.Errorf("first entry = %+v, want %s woken", entries[0], woken.MAC)
+	}
+	if entries[1].MAC != failed.MAC || entries[1].Success || entries[1].Error != failed.Error {
+		t.Errorf("second entry = %+v, want %s failed", entries[1], failed.MAC)
+	}
+}
+
+func TestAppendHistoryRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	res := result{target: target{MAC: "18:18:18:18:18:18"}, MAC: "18:18:18:18:18:18", Success: true}
+
+	// Just under the limit the file is appended to as is.
+	full := bytes.Repeat([]byte("x"), historyMaxSize-1)
+	full[len(full)-1] = '\n'
+	if err := os.WriteFile(path, full, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".1", []byte("older\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendHistory(path, []result{res}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() <= historyMaxSize-1 {
+		t.Fatalf("history is %d bytes, want it appended to", info.Size())
+	}
+
+	// Past the limit it's rotated to PATH.1, replacing the one there.
+	grown, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := appendHistory(path, []result{res}); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rotated, grown) {
+		t.Errorf("%s.1 is %d bytes, want the %d bytes of the rotated history", path, len(rotated), len(grown))
+	}
+	if entries := historyLines(t, path); len(entries) != 1 || entries[0].MAC != res.MAC {
+		t.Errorf("history after rotating = %+v, want just the new entry", entries)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// runHooks runs the -on-success or -on-failure command for every target in
+// results, depending on how waking it went. Dry runs and input lines which
+// didn't parse run neither. A failing hook is only warned about, the outcome
+// of the wake stands.
+func runHooks(results []result) {
+	for _, res := range results {
+		command := cliFlags.OnSuccess
+		if res.err != nil {
+			command = cliFlags.OnFailure
+		}
+		if command == "" || res.target.MAC == "" || res.DryRun {
+			continue
+		}
+		if err := hookCommand(command, res).Run(); err != nil {
+			warnf("hook for %s failed: %s\n", res.target, err)
+		}
+	}
+}
+
+// hookCommand returns the shell command running a hook for res. The details of
+// res are passed in the environment rather than spliced into the command, so
+// that no alias name or error message is ever interpreted by the shell. The
+// destination isn't passed as WOL_BROADCAST or WOL_PORT, which would change the
+// defaults of a wol run by the hook.
+func hookCommand(command string, res result) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", command)
+	}
+
+	status := "success"
+	if res.err != nil {
+		status = "failure"
+	}
+	cmd.Env = append(os.Environ(),
+		"WOL_RESULT="+status,
+		"WOL_MAC="+res.MAC,
+		"WOL_NAME="+res.Name,
+		"WOL_DEST="+res.Broadcast,
+		"WOL_DEST_PORT="+strconv.Itoa(res.Port),
+		"WOL_INTERFACE="+res.Interface,
+		"WOL_ERROR="+res.Error,
+		"WOL_CODE="+res.Code,
+	)
+	// Whatever the hook prints mustn't end up in the -json output.
+	cmd.Stdout = os.Stdout
+	if cliFlags.JSON {
+		cmd.Stdout = os.Stderr
+	}
+	cmd.Stderr = os.Stderr
+	return cmd
+}
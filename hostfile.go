@@ -0,0 +1,81 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// readMACFile reads the MAC addresses listed in the file at path.
+func readMACFile(path string) ([]target, []error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	return readMACs(f, path)
+}
+
+// stdinIsPiped reports whether stdin is redirected from a file or pipe, rather
+// than attached to a terminal.
+func stdinIsPiped() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice == 0
+}
+
+// readMACs reads one MAC address per line from r, ignoring blank lines and `#`
+// comments. A MAC address may be followed by a comma and the subnet it is on,
+// whose directed broadcast address it is then woken through:
+//
+//	18:18:18:18:18:18,192.168.1.0/24
+//	18-18-18-18-18-19
+//
+// Lines which don't hold a valid entry are returned as errors prefixed with
+// name and the line number, so that the caller can report them without
+// abandoning the rest of the batch.
+func readMACs(r io.Reader, name string) ([]target, []error, error) {
+	var entries []target
+	var badLines []error
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		macAddr, cidr, hasCIDR := strings.Cut(line, ",")
+		entry := target{MAC: strings.TrimSpace(macAddr)}
+		if _, err := wol.MagicPacketNew(entry.MAC); err != nil {
+			badLines = append(badLines, fmt.Errorf("%s:%d: %w", name, lineNo, err))
+			continue
+		}
+		if hasCIDR {
+			bcast, err := broadcastForCIDR(strings.TrimSpace(cidr))
+			if err != nil {
+				badLines = append(badLines, fmt.Errorf("%s:%d: %w", name, lineNo, err))
+				continue
+			}
+			entry.Broadcast = bcast
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return entries, badLines, nil
+}
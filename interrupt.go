@@ -0,0 +1,49 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// interruptGrace is how long the sends in flight when a batch is interrupted
+// get to finish, before they are cancelled too.
+const interruptGrace = 2 * time.Second
+
+// errInterrupted is returned when SIGINT or SIGTERM stopped a batch before
+// every target was attempted.
+var errInterrupted = errors.New("interrupted")
+
+// interruptContext returns a context which is done once the process receives
+// SIGINT or SIGTERM, the signals shells and orchestrators stop a run with.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// graceContext returns a context which is done grace after ctx is, so that
+// work already under way when ctx is cancelled gets to finish.
+func graceContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	graced, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-graced.Done():
+			return
+		}
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-graced.Done():
+		}
+	}()
+	return graced, cancel
+}
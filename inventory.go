@@ -0,0 +1,170 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// isInventoryFile reports whether the alias file at path is a YAML inventory
+// rather than the line based alias format.
+func isInventoryFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// parseInventory reads host aliases from a YAML inventory, a list of host
+// entries optionally under a top-level `hosts:` key:
+//
+//	hosts:
+//	  - name: nas
+//	    mac: 18:18:18:18:18:18
+//	    broadcast: 192.168.1.255
+//	    ip: 192.168.1.10
+//	    tags: [lab, servers]
+//	  - name: laptop
+//	    mac: 18-18-18-18-18-19
+//	    port: 7
+//
+// Only this subset of YAML is understood, which keeps wol free of third-party
+// dependencies: scalars (optionally quoted), and tags given as a flow list, a
+// comma separated string or a block list of `- tag` lines.
+func parseInventory(r io.Reader, name string) (map[string]target, error) {
+	aliases := map[string]target{}
+
+	var (
+		t          *target
+		entryLine  int // line the current entry started on
+		entryDepth int // indentation of the current entry's "-"
+		inTags     bool
+	)
+	finish := func() error {
+		if t == nil {
+			return nil
+		}
+		switch {
+		case t.Name == "":
+			return fmt.Errorf("%s:%d: host entry has no name", name, entryLine)
+		case t.MAC == "":
+			return fmt.Errorf("%s:%d: host entry %s has no mac", name, entryLine, t.Name)
+		}
+		if _, ok := aliases[t.Name]; ok {
+			return fmt.Errorf("%s:%d: duplicate alias %s", name, entryLine, t.Name)
+		}
+		aliases[t.Name] = *t
+		t = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := stripYAMLComment(scanner.Text())
+		content := strings.TrimSpace(line)
+		if content == "" || content == "---" {
+			continue
+		}
+		depth := len(line) - len(strings.TrimLeft(line, " "))
+
+		isItem := content == "-" || strings.HasPrefix(content, "- ")
+		item := strings.TrimSpace(strings.TrimPrefix(content, "-"))
+
+		switch {
+		case depth == 0 && content == "hosts:":
+			continue
+		case isItem && inTags && t != nil && depth > entryDepth:
+			t.Tags = append(t.Tags, unquoteYAML(item))
+			continue
+		case isItem:
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			t, entryLine, entryDepth, inTags = &target{}, lineNo, depth, false
+			if item == "" {
+				continue
+			}
+			content = item
+		case t == nil:
+			return nil, fmt.Errorf("%s:%d: expected a host entry starting with \"- \"", name, lineNo)
+		}
+
+		key, value, ok := strings.Cut(content, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY: VALUE, got %q", name, lineNo, content)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if err := t.setInventoryField(key, value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, lineNo, err)
+		}
+		inTags = key == "tags" && value == ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
+
+// setInventoryField applies a key of a YAML inventory host entry, validating
+// the MAC address like any other alias.
+func (t *target) setInventoryField(key, value string) error {
+	value = unquoteYAML(value)
+	switch key {
+	case "name":
+		t.Name = value
+	case "mac":
+		if _, err := wol.MagicPacketNew(value); err != nil {
+			return err
+		}
+		t.MAC = value
+	case "broadcast":
+		t.Broadcast = value
+	case "ip":
+		t.IP = value
+	case "port":
+		return t.setPort(value)
+	case "tags":
+		list := strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		for _, tag := range strings.Split(list, ",") {
+			if tag = unquoteYAML(strings.TrimSpace(tag)); tag != "" {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+	default:
+		return fmt.Errorf("unexpected key %q (expected name, mac, broadcast, ip, port or tags)", key)
+	}
+	return nil
+}
+
+// stripYAMLComment drops a trailing `#` comment from line. As in YAML, a `#`
+// only starts a comment at the start of the line or after a space.
+func stripYAMLComment(line string) string {
+	if strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return ""
+	}
+	if idx := strings.Index(line, " #"); idx >= 0 {
+		return line[:idx]
+	}
+	return line
+}
+
+// unquoteYAML removes the single or double quotes around a scalar, if any.
+func unquoteYAML(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,206 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestParseInventory(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		yaml string
+		want map[string]target
+	}{
+		{
+			name: "hosts key",
+			yaml: `hosts:
+  - name: nas
+    mac: 18:18:18:18:18:18
+    broadcast: 192.168.1.255
+    ip: 192.168.1.10
+    port: 7
+`,
+			want: map[string]target{
+				"nas": {Name: "nas", MAC: "18:18:18:18:18:18", Broadcast: "192.168.1.255", IP: "192.168.1.10", Port: 7},
+			},
+		},
+		{
+			name: "bare list with document start",
+			yaml: `---
+- name: nas
+  mac: 18:18:18:18:18:18
+- name: laptop
+  mac: 18-18-18-18-18-19
+`,
+			want: map[string]target{
+				"nas":    {Name: "nas", MAC: "18:18:18:18:18:18"},
+				"laptop": {Name: "laptop", MAC: "18-18-18-18-18-19"},
+			},
+		},
+		{
+			name: "flow tags",
+			yaml: `- name: nas
+  mac: 181818181818
+  tags: [lab, "servers"]
+`,
+			want: map[string]target{
+				"nas": {Name: "nas", MAC: "181818181818", Tags: []string{"lab", "servers"}},
+			},
+		},
+		{
+			name: "comma separated tags",
+			yaml: `- name: nas
+  mac: 181818181818
+  tags: lab, servers
+`,
+			want: map[string]target{
+				"nas": {Name: "nas", MAC: "181818181818", Tags: []string{"lab", "servers"}},
+			},
+		},
+		{
+			name: "block tags",
+			yaml: `hosts:
+  - name: nas
+    tags:
+      - lab
+      - 'servers'
+    mac: 181818181818
+  - name: laptop
+    mac: 181818181819
+`,
+			want: map[string]target{
+				"nas":    {Name: "nas", MAC: "181818181818", Tags: []string{"lab", "servers"}},
+				"laptop": {Name: "laptop", MAC: "181818181819"},
+			},
+		},
+		{
+			name: "quoted values",
+			yaml: `- name: "my nas"
+  mac: '18:18:18:18:18:18'
+  broadcast: "192.168.1.255"
+`,
+			want: map[string]target{
+				"my nas": {Name: "my nas", MAC: "18:18:18:18:18:18", Broadcast: "192.168.1.255"},
+			},
+		},
+		{
+			name: "comments",
+			yaml: `# lab machines
+hosts: # every host
+  - name: nas # the big one
+    mac: 18:18:18:18:18:18
+
+    # no port
+  - name: pc#2
+    mac: 18:18:18:18:18:19
+`,
+			want: map[string]target{
+				"nas":  {Name: "nas", MAC: "18:18:18:18:18:18"},
+				"pc#2": {Name: "pc#2", MAC: "18:18:18:18:18:19"},
+			},
+		},
+		{
+			name: "entry starting on its own line",
+			yaml: `-
+  name: nas
+  mac: 18:18:18:18:18:18
+`,
+			want: map[string]target{
+				"nas": {Name: "nas", MAC: "18:18:18:18:18:18"},
+			},
+		},
+		{
+			name: "empty",
+			yaml: "# nothing yet\n",
+			want: map[string]target{},
+		},
+	} {
+		got, err := parseInventory(strings.NewReader(tc.yaml), "hosts.yaml")
+		if err != nil {
+			t.Errorf("%s: parseInventory failed: %s", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: parseInventory = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseInventoryErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name: "missing name",
+			yaml: `- name: nas
+  mac: 18:18:18:18:18:18
+- mac: 18:18:18:18:18:19
+`,
+			wantErr: "hosts.yaml:3: host entry has no name",
+		},
+		{
+			name: "missing mac",
+			yaml: `hosts:
+  - name: nas
+    ip: 192.168.1.10
+`,
+			wantErr: "hosts.yaml:2: host entry nas has no mac",
+		},
+		{
+			name: "duplicate name",
+			yaml: `- name: nas
+  mac: 18:18:18:18:18:18
+- name: nas
+  mac: 18:18:18:18:18:19
+`,
+			wantErr: "hosts.yaml:3: duplicate alias nas",
+		},
+		{
+			name: "invalid mac",
+			yaml: `- name: nas
+  mac: 18:18:18:18:18
+`,
+			wantErr: "hosts.yaml:2: 18:18:18:18:18 is not a IEEE 802 MAC-48 address",
+		},
+		{
+			name: "bad port",
+			yaml: `- name: nas
+  mac: 18:18:18:18:18:18
+  port: 70000
+`,
+			wantErr: `hosts.yaml:3: port "70000" is not a number in the range 1-65535`,
+		},
+		{
+			name: "unknown key",
+			yaml: `- name: nas
+  mac: 18:18:18:18:18:18
+  colour: blue
+`,
+			wantErr: `hosts.yaml:3: unexpected key "colour"`,
+		},
+		{
+			name:    "no entry",
+			yaml:    "name: nas\n",
+			wantErr: `hosts.yaml:1: expected a host entry starting with "- "`,
+		},
+		{
+			name: "not a key",
+			yaml: `- name: nas
+  just text
+`,
+			wantErr: `hosts.yaml:2: expected KEY: VALUE, got "just text"`,
+		},
+	} {
+		_, err := parseInventory(strings.NewReader(tc.yaml), "hosts.yaml")
+		if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+			t.Errorf("%s: parseInventory = %v, want an error starting %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// listedAlias is a host alias as printed by `wol list -json`.
+type listedAlias struct {
+	Name      string   `json:"name"`
+	MAC       string   `json:"mac"`
+	Broadcast string   `json:"broadcast,omitempty"`
+	Port      int      `json:"port,omitempty"`
+	IP        string   `json:"ip,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// listCmd prints the host aliases of the alias file in use, sorted by name.
+func listCmd(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected arguments to list: %q", args)
+	}
+
+	aliases, err := loadAliases(cliFlags.Config)
+	if isMissing(err) {
+		aliases, err = map[string]target{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	listed := make([]listedAlias, 0, len(aliases))
+	for _, t := range aliases {
+		listed = append(listed, listedAlias{
+			Name:      t.Name,
+			MAC:       t.canonicalMAC(),
+			Broadcast: t.Broadcast,
+			Port:      t.Port,
+			IP:        t.IP,
+			Tags:      t.Tags,
+		})
+	}
+	sort.Slice(listed, func(i, j int) bool {
+		return listed[i].Name < listed[j].Name
+	})
+
+	if cliFlags.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(listed)
+	}
+	if len(listed) == 0 {
+		path := cliFlags.Config
+		if paths := aliasPaths(); path == "" && len(paths) > 0 {
+			path = paths[0]
+		}
+		fmt.Printf("No host aliases configured yet, add them to %s (one NAME MAC_ADDRESS per line).\n", path)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tMAC\tBROADCAST\tPORT\tIP\tTAGS")
+	for _, a := range listed {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", a.Name, a.MAC, orDash(a.Broadcast), orDash(portString(a.Port)), orDash(a.IP), orDash(strings.Join(a.Tags, ",")))
+	}
+	return w.Flush()
+}
+
+// portString formats a port for the list table, leaving an unset port empty.
+func portString(port int) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(port)
+}
+
+// orDash stands in "-" for an unset table cell.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,111 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// received is a magic packet picked up by -listen.
+type received struct {
+	From     string `json:"from"`
+	MAC      string `json:"mac"`
+	Password string `json:"password,omitempty"`
+}
+
+// listenCmd prints every magic packet arriving on the -port until interrupted,
+// to confirm whether packets reach this segment at all.
+func listenCmd() error {
+	if err := applyEnvDefaults(); err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	conn, err := listenConn()
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	if cliFlags.Group != "" {
+		infof("Joined multicast group %s\n", cliFlags.Group)
+	}
+	infof("Listening for magic packets on %s, press Ctrl+C to stop\n", conn.LocalAddr())
+	enc := json.NewEncoder(os.Stdout)
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				infof("Stopped listening\n")
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			debugf(1, "read failed: %s", err)
+			continue
+		}
+
+		// Anything else on the port, including a packet sent with -tag, is
+		// still reported, as it shows the packets do reach this segment.
+		mp, err := wol.MagicPacketUnmarshal(buf[:n])
+		if err != nil {
+			warnf("ignored %d-byte non-magic datagram from %s: %s\n", n, from, err)
+			continue
+		}
+
+		pkt := received{
+			From:     from.String(),
+			MAC:      mp.MAC().String(),
+			Password: hex.EncodeToString(mp.Password()),
+		}
+		if cliFlags.JSON {
+			if err := enc.Encode(pkt); err != nil {
+				return err
+			}
+			continue
+		}
+		if pkt.Password != "" {
+			fmt.Printf("%s: magic packet for %s (SecureOn password %s)\n", pkt.From, pkt.MAC, pkt.Password)
+			continue
+		}
+		fmt.Printf("%s: magic packet for %s\n", pkt.From, pkt.MAC)
+	}
+}
+
+// listenConn opens the socket -listen reads from: every packet arriving on the
+// -port, or with -group those sent to the multicast group alongside broadcasts.
+func listenConn() (net.PacketConn, error) {
+	if cliFlags.Group == "" {
+		return net.ListenPacket("udp", net.JoinHostPort("", strconv.Itoa(cliFlags.Port)))
+	}
+
+	group := net.ParseIP(cliFlags.Group)
+	if group.To4() == nil || !group.IsMulticast() {
+		return nil, fmt.Errorf("-group %s is not an IPv4 multicast address", cliFlags.Group)
+	}
+	var ifi *net.Interface
+	if cliFlags.BroadcastInterface != "" {
+		var err error
+		if ifi, err = net.InterfaceByName(cliFlags.BroadcastInterface); err != nil {
+			return nil, fmt.Errorf("interface %s: %w", cliFlags.BroadcastInterface, err)
+		}
+	}
+	return net.ListenMulticastUDP("udp4", ifi, &net.UDPAddr{IP: group, Port: cliFlags.Port})
+}
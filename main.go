@@ -4,202 +4,1201 @@ package main
 
 import (
 	"bytes"
-	"encoding/binary"
+	"context"
+	"encoding/hex"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"regexp"
-)
-
-////////////////////////////////////////////////////////////////////////////////
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-var (
-	delims = ":-"
-	reMAC  = regexp.MustCompile(`^([0-9a-fA-F]{2}[` + delims + `]){5}([0-9a-fA-F]{2})$`)
+	"wol/wol"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
 
-// MACAddress represents a 6 byte network mac address.
-type MACAddress [6]byte
+var cliFlags struct {
+	After              time.Duration
+	AllInterfaces      bool
+	ARP                string
+	Broadcast          string
+	BroadcastAll       bool
+	At                 string
+	AtPast             string
+	Auto               bool
+	BroadcastInterface string
+	CIDR               string
+	Config             string
+	Count              int
+	Daemon             bool
+	DaemonInterval     time.Duration
+	DryRun             bool
+	Dump               bool
+	File               string
+	Force              bool
+	Group              string
+	History            string
+	Host               string
+	InterfaceIndex     int
+	Interval           time.Duration
+	IPv6               bool
+	Jitter             float64
+	JSON               bool
+	Listen             bool
+	MetricsFile        string
+	NoResolve          bool
+	Out                string
+	OnFailure          string
+	OnSuccess          string
+	Parallel           int
+	Password           string
+	Port               int
+	Ports              string
+	Quiet              bool
+	Rate               float64
+	Repetitions        int
+	ResolveRetries     int
+	ResolveRetryDelay  time.Duration
+	Retry              int
+	Self               string
+	SourcePort         int
+	Tag                string
+	Target             string
+	TCP                bool
+	RetryDelay         time.Duration
+	Timeout            time.Duration
+	Verbose            verbosity
+	Version            bool
+	Wait               string
+	WaitBanner         string
+	WaitPort           int
+	WaitTimeout        time.Duration
+}
+
+func init() {
+	flag.DurationVar(&cliFlags.After, "after", 0, "wait this long before sending, e.g. 30m")
+	flag.StringVar(&cliFlags.At, "at", "", "wait until this local time of day before sending, e.g. 07:00")
+	flag.StringVar(&cliFlags.AtPast, "at-past", "tomorrow", "what to do when the -at time has already passed today: tomorrow or error")
+	flag.BoolVar(&cliFlags.Auto, "auto", false, "send to the directed broadcast address of the default route's subnet, falling back to 255.255.255.255")
+	flag.BoolVar(&cliFlags.AllInterfaces, "all-interfaces", false, "send the magic packet from every interface which is up and has an IPv4 address")
+	flag.StringVar(&cliFlags.ARP, "arp", "", "wake the machine with this IP, looking its MAC address up in the ARP cache")
+	flag.StringVar(&cliFlags.Broadcast, "broadcast", "", "broadcast IP to send the magic packet to, overriding a trailing BROADCAST_IP argument")
+	flag.BoolVar(&cliFlags.BroadcastAll, "broadcast-all-subnets", false, "send the magic packet to the directed broadcast address of every local IPv4 subnet, from its interface")
+	flag.StringVar(&cliFlags.BroadcastInterface, "interface", "", "network interface to send the magic packet from, by name, index or address, e.g. eth1, 3 or 192.168.1.0/24")
+	flag.IntVar(&cliFlags.InterfaceIndex, "interface-index", 0, "network interface to send the magic packet from, by its index as listed by ip link, instead of -interface")
+	flag.StringVar(&cliFlags.CIDR, "cidr", "", "broadcast to the directed broadcast address of this subnet, e.g. 192.168.1.0/24")
+	flag.StringVar(&cliFlags.Config, "config", "", "host alias file, or YAML inventory if named *.yaml (default ~/.config/wol/hosts, hosts.yaml or ~/.wol.conf)")
+	flag.IntVar(&cliFlags.Count, "count", 1, "number of times to send each magic packet")
+	flag.BoolVar(&cliFlags.Daemon, "daemon", false, "resend the magic packet every -daemon-interval until Ctrl+C, for devices which only listen now and then")
+	flag.DurationVar(&cliFlags.DaemonInterval, "daemon-interval", 2*time.Second, "delay between the magic packets resent by -daemon")
+	flag.BoolVar(&cliFlags.DryRun, "n", false, "shorthand for -dry-run")
+	flag.BoolVar(&cliFlags.DryRun, "dry-run", false, "build the magic packet and show where it would go, without sending it")
+	flag.BoolVar(&cliFlags.Dump, "dump", false, "print the magic packet as hex before sending it")
+	flag.StringVar(&cliFlags.File, "file", "", "file listing MAC addresses to wake, one per line")
+	flag.StringVar(&cliFlags.History, "history", "", "append a line recording when, by whom and how each MAC address was woken to this file, e.g. ~/.wol.log, rotated past 1 MiB")
+	flag.StringVar(&cliFlags.Host, "host", "", "send the magic packet to this hostname (or IP), e.g. a router forwarding the port to its LAN's broadcast address")
+	flag.DurationVar(&cliFlags.Interval, "interval", 100*time.Millisecond, "delay between repeated sends with -count")
+	flag.Float64Var(&cliFlags.Jitter, "jitter", 0, "randomize each -interval by up to plus or minus this percentage, e.g. 20")
+	flag.BoolVar(&cliFlags.IPv6, "6", false, "send over IPv6 to the all-nodes multicast group (ff02::1) instead of broadcasting")
+	flag.BoolVar(&cliFlags.JSON, "json", false, "print the results as JSON instead of progress messages")
+	flag.BoolVar(&cliFlags.Listen, "listen", false, "print the magic packets received on -port instead of sending any, until Ctrl+C")
+	flag.StringVar(&cliFlags.MetricsFile, "metrics-file", "", "write Prometheus counters for the run to this file, e.g. for the node_exporter textfile collector")
+	flag.BoolVar(&cliFlags.NoResolve, "no-resolve", false, "require the destination to be a literal IP address, never looking it up in DNS")
+	flag.StringVar(&cliFlags.OnFailure, "on-failure", "", "run this shell command for every MAC address which couldn't be woken, see the Readme for its environment")
+	flag.StringVar(&cliFlags.OnSuccess, "on-success", "", "run this shell command for every MAC address woken, see the Readme for its environment")
+	flag.StringVar(&cliFlags.Out, "out", "", "write the magic packet to this file (- for stdout) instead of sending it, e.g. to send it from another machine later")
+	flag.IntVar(&cliFlags.Parallel, "parallel", 1, "number of MAC addresses to wake at the same time")
+	flag.StringVar(&cliFlags.Password, "password", "", "SecureOn password to add to the magic packet, 4 or 6 hex bytes such as 01:02:03:04:05:06, or ascii:secret for 6 characters")
+	flag.IntVar(&cliFlags.Port, "port", wol.DefaultPort, "UDP port to send the magic packet to")
+	flag.StringVar(&cliFlags.Ports, "ports", "", "send to each of these comma separated ports or ranges, e.g. 7,9 for a fleet listening on either, reporting each separately")
+	flag.BoolVar(&cliFlags.Quiet, "q", false, "shorthand for -quiet")
+	flag.BoolVar(&cliFlags.Quiet, "quiet", false, "suppress informational output, only print errors to stderr")
+	flag.Float64Var(&cliFlags.Rate, "rate", 0, "send at most this many magic packets per second across the whole batch (0 means unlimited)")
+	flag.IntVar(&cliFlags.Repetitions, "repetitions", wol.DefaultRepetitions, "advanced: number of times the magic packet repeats the MAC address, for devices expecting other than 16")
+	flag.IntVar(&cliFlags.ResolveRetries, "resolve-retries", 2, "retry a destination hostname lookup this many times when the resolver fails transiently")
+	flag.DurationVar(&cliFlags.ResolveRetryDelay, "resolve-retry-delay", time.Second, "delay between the retries of -resolve-retries")
+	flag.Var(&cliFlags.Verbose, "v", "log each step of sending to stderr, repeat (-v -v) to also hex dump the packet")
+	flag.IntVar(&cliFlags.Retry, "retry", 0, "with -wait, resend up to this many times with increasing delays until the host is up")
+	flag.DurationVar(&cliFlags.RetryDelay, "retry-delay", wol.DefaultRetryDelay, "how long -retry waits after the first packet, doubling after each resend")
+	flag.BoolVar(&cliFlags.Version, "version", false, "print the version and exit")
+	flag.StringVar(&cliFlags.Self, "self", "", "wake this machine's own network interface, by name, e.g. to test the send path end to end")
+	flag.IntVar(&cliFlags.SourcePort, "source-port", 0, "send from this local port, with the -interface address if given (0 lets the OS pick)")
+	flag.StringVar(&cliFlags.Tag, "tag", "", "advanced, non-standard: append these bytes to the magic packet, e.g. a timestamp for diagnostic receivers; network cards may ignore such packets")
+	flag.StringVar(&cliFlags.Target, "target", "", "destination and port in one, e.g. 192.168.1.255:9 or [ff02::1%eth0]:9, in place of a broadcast IP and -port")
+	flag.BoolVar(&cliFlags.TCP, "tcp", false, "send the same magic packet bytes over TCP to -host, for relays which only forward TCP")
+	flag.DurationVar(&cliFlags.Timeout, "timeout", 0, "give up on each MAC address after this long, e.g. 5s (0 means no overall limit, each write still gives up after 5s)")
+	flag.StringVar(&cliFlags.Wait, "wait", "", "after sending, wait for this IP, hostname or alias (with an ip=) to accept TCP connections")
+	flag.StringVar(&cliFlags.WaitBanner, "wait-banner", "", "only confirm the -wait host up once the first line it sends matches this substring or regular expression, e.g. OpenSSH")
+	flag.IntVar(&cliFlags.WaitPort, "wait-port", wol.DefaultWaitPort, "TCP port polled by -wait")
+	flag.DurationVar(&cliFlags.WaitTimeout, "wait-timeout", 2*time.Minute, "how long -wait keeps polling before giving up")
+}
 
-// MagicPacket is constituted of 6 bytes of 0xFF followed by 16-groups of the
-// destination MAC address.
-type MagicPacket struct {
-	header  [6]byte
-	payload [16]MACAddress
+// flagGiven reports whether the named flag was set on the command line, rather
+// than left at its default.
+func flagGiven(name string) bool {
+	given := false
+	activeFlags.Visit(func(f *flag.Flag) {
+		given = given || f.Name == name
+	})
+	return given
 }
 
-// New returns a magic packet based on a mac address string.
-func MagicPacketNew(mac string) (*MagicPacket, error) {
-	var packet MagicPacket
-	var macAddr MACAddress
+// verbosity is a flag which may be repeated, e.g. `-v -v`, each time raising
+// the level of detail logged.
+type verbosity int
+
+func (v *verbosity) String() string {
+	return strconv.Itoa(int(*v))
+}
 
-	hwAddr, err := net.ParseMAC(mac)
+func (v *verbosity) Set(s string) error {
+	on, err := strconv.ParseBool(s)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	if on {
+		*v++
+	} else {
+		*v = 0
+	}
+	return nil
+}
+
+func (v *verbosity) IsBoolFlag() bool {
+	return true
+}
 
-	// We only support 6 byte MAC addresses since it is much harder to use the
-	// binary.Write(...) interface when the size of the MagicPacket is dynamic.
-	if !reMAC.MatchString(mac) {
-		return nil, fmt.Errorf("%s is not a IEEE 802 MAC-48 address", mac)
+// parseArgs parses the flags in args, which may be interleaved with the
+// positional arguments, e.g. `wol 18-18-18-18-18-18 -port 7`. Everything after
+// a "--" is positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		// Parse stops at the first non-flag argument, or just after a "--".
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
 	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// target is a single machine to wake, either given directly as a MAC address
+// or looked up by its alias.
+type target struct {
+	Name      string
+	MAC       string
+	Broadcast string
+	IP        string
+	Port      int
+	Tags      []string
+
+	// Interface overrides -interface, when -all-interfaces wakes the target
+	// from every interface in turn.
+	Interface string
+}
 
-	// Copy bytes from the returned HardwareAddr -> a fixed size MACAddress.
-	for idx := range macAddr {
-		macAddr[idx] = hwAddr[idx]
+// resolveTarget looks arg up in the host aliases, falling back to treating it
+// as a literal MAC address. A MAC address listed under an alias, in whichever
+// form, resolves to that alias.
+func resolveTarget(arg string, aliases map[string]target) target {
+	if t, ok := aliases[arg]; ok {
+		return t
 	}
+	if macAddr, err := wol.NormalizeMAC(arg); err == nil {
+		for _, t := range aliases {
+			if aliasMAC, err := wol.NormalizeMAC(t.MAC); err == nil && aliasMAC == macAddr {
+				return t
+			}
+		}
+	}
+	return target{MAC: arg}
+}
 
-	// Setup the header which is 6 repetitions of 0xFF.
-	for idx := range packet.header {
-		packet.header[idx] = 0xFF
+// resolveEntries resolves the MAC addresses read from a file like the ones on
+// the command line, a subnet given on the entry's line taking precedence over
+// the alias' broadcast IP.
+func resolveEntries(entries []target, aliases map[string]target) []target {
+	targets := make([]target, 0, len(entries))
+	for _, entry := range entries {
+		t := resolveTarget(entry.MAC, aliases)
+		if entry.Broadcast != "" {
+			t.Broadcast = entry.Broadcast
+		}
+		targets = append(targets, t)
 	}
+	return targets
+}
 
-	// Setup the payload which is 16 repetitions of the MAC addr.
-	for idx := range packet.payload {
-		packet.payload[idx] = macAddr
+// dedupTargets drops the targets whose MAC address, compared regardless of its
+// form, was already listed, keeping the first of each.
+func dedupTargets(targets []target) []target {
+	seen := map[wol.MACAddress]bool{}
+	unique := targets[:0:0]
+	for _, t := range targets {
+		macAddr, err := wol.NormalizeMAC(t.MAC)
+		if err == nil && seen[macAddr] {
+			infof("Skipping %s, it is already listed\n", t)
+			continue
+		}
+		seen[macAddr] = true
+		unique = append(unique, t)
 	}
+	return unique
+}
 
-	return &packet, nil
+// String describes the target for progress messages, using the canonical form
+// of its MAC address whenever it is valid.
+func (t target) String() string {
+	s := t.canonicalMAC()
+	if t.Name != "" {
+		s = fmt.Sprintf("%s (%s)", t.Name, s)
+	}
+	if t.Interface != "" {
+		s += " via " + t.Interface
+	}
+	if cliFlags.Ports != "" {
+		s += fmt.Sprintf(" on port %d", t.Port)
+	}
+	return s
 }
 
-// Marshal serializes the magic packet structure into a 102 byte slice.
-func (mp *MagicPacket) Marshal() ([]byte, error) {
-	var buf bytes.Buffer
-	if err := binary.Write(&buf, binary.BigEndian, mp); err != nil {
-		return nil, err
+// canonicalMAC returns the MAC address as xx:xx:xx:xx:xx:xx, or as it was typed
+// if it isn't valid.
+func (t target) canonicalMAC() string {
+	macAddr, err := wol.NormalizeMAC(t.MAC)
+	if err != nil {
+		return t.MAC
 	}
+	return macAddr.String()
+}
 
-	return buf.Bytes(), nil
+// looksLikeMAC reports whether arg is meant as a MAC address, even one that
+// MagicPacketNew will go on to reject.
+func looksLikeMAC(arg string) bool {
+	if _, err := net.ParseMAC(arg); err == nil {
+		return true
+	}
+	_, err := wol.NormalizeMAC(arg)
+	return err == nil
 }
 
-////////////////////////////////////////////////////////////////////////////////
+// Run the wake command.
+func wakeCmd(args []string) error {
+	if err := applyEnvDefaults(); err != nil {
+		return err
+	}
 
-// ipFromInterface returns a `*net.UDPAddr` from a network interface name.
-func ipFromInterface(iface string) (*net.UDPAddr, error) {
-	ief, err := net.InterfaceByName(iface)
-	if err != nil {
-		return nil, err
+	if flagGiven("interface-index") {
+		if cliFlags.BroadcastInterface != "" {
+			return usageErrorf("both -interface and -interface-index specified")
+		}
+		name, err := interfaceNameByIndex(cliFlags.InterfaceIndex)
+		if err != nil {
+			return err
+		}
+		cliFlags.BroadcastInterface = name
 	}
 
-	addrs, err := ief.Addrs()
-	if err == nil && len(addrs) <= 0 {
-		err = fmt.Errorf("no address associated with interface %s", iface)
+	aliases, err := loadAliases(cliFlags.Config)
+	if err != nil {
+		return err
 	}
+
+	// A "-" argument, or no arguments at all with data piped in, reads MAC
+	// addresses from stdin just like -file does.
+	otherTargets := cliFlags.File != "" || cliFlags.ARP != "" || cliFlags.Self != ""
+	names, broadcastIP, fromStdin, err := splitWakeArgs(args, aliases, otherTargets)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if len(args) == 0 && !otherTargets && stdinIsPiped() {
+		fromStdin = true
+	}
+	if looksLikeMAC(cliFlags.Broadcast) {
+		return usageErrorf("-broadcast %s is a MAC address, not a broadcast IP: pass the MAC address as an argument, e.g. wol -broadcast 192.168.1.255 %s", cliFlags.Broadcast, cliFlags.Broadcast)
 	}
 
-	// Validate that one of the addrs is a valid network IP address.
-	for _, addr := range addrs {
-		switch ip := addr.(type) {
-		case *net.IPNet:
-			if !ip.IP.IsLoopback() && ip.IP.To4() != nil {
-				return &net.UDPAddr{
-					IP: ip.IP,
-				}, nil
+	// The -broadcast flag is the explicit form of the trailing argument.
+	if cliFlags.Broadcast != "" {
+		if broadcastIP != "" {
+			warnf("-broadcast %s overrides the broadcast IP argument %s\n", cliFlags.Broadcast, broadcastIP)
+		}
+		broadcastIP = cliFlags.Broadcast
+	}
+
+	// A hostname given with -host is resolved when sending, which lets a router
+	// with a forwarded port relay the packet onto its LAN from the internet.
+	if cliFlags.Host != "" {
+		if broadcastIP != "" {
+			return usageErrorf("both -host %s and broadcast IP %s specified", cliFlags.Host, broadcastIP)
+		}
+		broadcastIP = cliFlags.Host
+	}
+
+	// A subnet given with -cidr is turned into its directed broadcast address.
+	if cliFlags.CIDR != "" {
+		if broadcastIP != "" {
+			return usageErrorf("both -cidr %s and destination %s specified", cliFlags.CIDR, broadcastIP)
+		}
+		cidrIP, err := broadcastForCIDR(cliFlags.CIDR)
+		if err != nil {
+			return err
+		}
+		broadcastIP = cidrIP
+	}
+
+	// A -target HOST:PORT sets the destination and the port in one go, as
+	// pasted from elsewhere. Neither may also be given on its own.
+	if cliFlags.Target != "" {
+		switch {
+		case broadcastIP != "":
+			return usageErrorf("both -target %s and destination %s specified", cliFlags.Target, broadcastIP)
+		case flagGiven("port"):
+			return usageErrorf("-target %s already has a port, drop -port", cliFlags.Target)
+		}
+		host, port, err := splitTarget(cliFlags.Target)
+		if err != nil {
+			return err
+		}
+		broadcastIP, cliFlags.Port = host, port
+	}
+
+	// -auto works out the subnet of the default route, for which the plain
+	// 255.255.255.255 is the best guess left when it can't.
+	if cliFlags.Auto {
+		switch {
+		case broadcastIP != "":
+			return usageErrorf("both -auto and destination %s specified", broadcastIP)
+		case cliFlags.IPv6 || cliFlags.TCP:
+			return usageErrorf("-auto only broadcasts over IPv4 UDP")
+		}
+		if subnet, err := wol.DefaultRouteBroadcast(); err != nil {
+			warnf("-auto: %s, broadcasting to %s instead\n", err, defaultBroadcast())
+		} else {
+			debugf(1, "-auto: default route is via %s on %s", subnet.Interface, subnet.Subnet)
+			broadcastIP = subnet.Broadcast.String()
+		}
+	}
+
+	// An @tag argument wakes every alias with that tag, reported as a batch
+	// however many there are.
+	targets := make([]target, 0, len(names))
+	grouped := false
+	for _, name := range names {
+		if tag := strings.TrimPrefix(name, "@"); tag != name {
+			tagged, err := taggedAliases(tag, aliases)
+			if err != nil {
+				return err
 			}
+			targets = append(targets, tagged...)
+			grouped = true
+			continue
 		}
+		targets = append(targets, resolveTarget(name, aliases))
 	}
-	return nil, fmt.Errorf("no address associated with interface %s", iface)
-}
 
-////////////////////////////////////////////////////////////////////////////////
+	// A machine known only by its IP address is woken by its cached MAC.
+	if cliFlags.ARP != "" {
+		macAddr, err := wol.LookupMAC(cliFlags.ARP)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target{Name: cliFlags.ARP, MAC: macAddr.String()})
+	}
 
-// Run the wake command.
-func wakeCmd(args []string) error {
-	if len(args) < 2 {
-		return errors.New("No mac address specified to wake command")
+	// A local interface is woken by its own MAC, which makes for a quick end
+	// to end test.
+	if cliFlags.Self != "" {
+		mac, err := interfaceMAC(cliFlags.Self)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target{Name: cliFlags.Self, MAC: mac})
+	}
+
+	// Append the MAC addresses read from the hosts file and stdin, if given.
+	var badLines []error
+	if cliFlags.File != "" {
+		fileEntries, fileErrs, err := readMACFile(cliFlags.File)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, resolveEntries(fileEntries, aliases)...)
+		badLines = fileErrs
+	}
+	if fromStdin {
+		stdinEntries, stdinErrs, err := readMACs(os.Stdin, "stdin")
+		if err != nil {
+			return err
+		}
+		targets = append(targets, resolveEntries(stdinEntries, aliases)...)
+		badLines = append(badLines, stdinErrs...)
+	}
+
+	targets = dedupTargets(targets)
+	if len(targets) == 0 && len(badLines) == 0 {
+		return usageErrorf("No mac address specified to wake command")
+	}
+	// Every target is woken once from each interface, and reported as such.
+	if cliFlags.AllInterfaces {
+		if cliFlags.BroadcastInterface != "" {
+			return usageErrorf("both -interface and -all-interfaces specified")
+		}
+		ifaces, err := wol.BroadcastInterfacesSkipping(func(iface string, err error) {
+			infof("Skipping interface %s: %s\n", iface, err)
+		})
+		if err != nil {
+			return err
+		}
+		perIface := make([]target, 0, len(targets)*len(ifaces))
+		for _, t := range targets {
+			for _, iface := range ifaces {
+				t.Interface = iface
+				perIface = append(perIface, t)
+			}
+		}
+		targets = perIface
+	}
+
+	// Every target is woken on each local subnet, through its interface, for
+	// when it's on one of them but there's no telling which.
+	if cliFlags.BroadcastAll {
+		switch {
+		case broadcastIP != "":
+			return usageErrorf("both -broadcast-all-subnets and destination %s specified", broadcastIP)
+		case cliFlags.BroadcastInterface != "" || cliFlags.AllInterfaces:
+			return usageErrorf("-broadcast-all-subnets picks the interfaces itself, drop -interface and -all-interfaces")
+		case cliFlags.IPv6 || cliFlags.TCP:
+			return usageErrorf("-broadcast-all-subnets only broadcasts over IPv4 UDP")
+		}
+		subnets, err := wol.SubnetBroadcasts()
+		if err != nil {
+			return err
+		}
+		perSubnet := make([]target, 0, len(targets)*len(subnets))
+		for _, t := range targets {
+			for _, subnet := range subnets {
+				t.Broadcast = subnet.Broadcast.String()
+				t.Interface = subnet.Interface
+				perSubnet = append(perSubnet, t)
+			}
+		}
+		targets = perSubnet
+	}
+
+	// Every target is woken on each of the -ports, for fleets which mix
+	// devices listening on 7 and on 9.
+	if cliFlags.Ports != "" {
+		switch {
+		case flagGiven("port"):
+			return usageErrorf("both -port and -ports specified")
+		case cliFlags.Target != "":
+			return usageErrorf("-target %s already has a port, drop -ports", cliFlags.Target)
+		}
+		ports, err := parsePorts(cliFlags.Ports)
+		if err != nil {
+			return err
+		}
+		perPort := make([]target, 0, len(targets)*len(ports))
+		for _, t := range targets {
+			for _, port := range ports {
+				t.Port = port
+				perPort = append(perPort, t)
+			}
+		}
+		targets = perPort
+	}
+
+	// TCP has no broadcast to fall back on.
+	if cliFlags.TCP && broadcastIP == "" {
+		for _, t := range targets {
+			if t.Broadcast == "" {
+				return usageErrorf("-tcp needs a destination for %s, e.g. -host relay.example.com", t)
+			}
+		}
+	}
+
+	if cliFlags.Parallel < 1 {
+		return usageErrorf("-parallel %d must be at least 1", cliFlags.Parallel)
+	}
+	if cliFlags.Parallel > 1 && cliFlags.SourcePort != 0 {
+		return usageErrorf("-parallel workers can't share one -source-port")
+	}
+	if cliFlags.Rate < 0 {
+		return usageErrorf("-rate %g must not be negative", cliFlags.Rate)
+	}
+	if cliFlags.Repetitions < 1 {
+		return usageErrorf("-repetitions %d must be at least 1", cliFlags.Repetitions)
+	}
+	if cliFlags.Repetitions != wol.DefaultRepetitions && cliFlags.Retry > 0 {
+		return usageErrorf("-retry always sends the standard 16 repetitions, drop -repetitions")
+	}
+	if cliFlags.Tag != "" {
+		if cliFlags.Retry > 0 {
+			return usageErrorf("-retry always sends standard magic packets, drop -tag")
+		}
+		warnf("-tag makes the magic packet non-standard, network cards may ignore it\n")
+	}
+	banner, err := waitBanner()
+	if err != nil {
+		return err
+	}
+	if cliFlags.Jitter < 0 || cliFlags.Jitter > 100 {
+		return usageErrorf("-jitter %g is not a percentage in the range 0-100", cliFlags.Jitter)
+	}
+	if cliFlags.ResolveRetries < 0 {
+		return usageErrorf("-resolve-retries %d must not be negative", cliFlags.ResolveRetries)
+	}
+
+	opts := []wol.Option{
+		wol.WithCount(cliFlags.Count),
+		wol.WithInterval(cliFlags.Interval),
+		wol.WithJitter(cliFlags.Jitter / 100),
+		wol.WithSourcePort(cliFlags.SourcePort),
+		wol.WithResolveRetries(cliFlags.ResolveRetries, cliFlags.ResolveRetryDelay),
+	}
+	if cliFlags.Timeout > 0 {
+		opts = append(opts, wol.WithWriteTimeout(cliFlags.Timeout))
+	}
+	if cliFlags.Rate > 0 {
+		limiter := newRateLimiter(cliFlags.Rate)
+		defer limiter.stop()
+		opts = append(opts, wol.WithThrottle(limiter.wait))
+	}
+	if cliFlags.NoResolve {
+		opts = append(opts, wol.WithNoResolve())
+	} else {
+		// Targets sharing a destination hostname resolve it only once.
+		opts = append(opts, wol.WithAddrCache(&wol.AddrCache{}))
+	}
+	if cliFlags.Password != "" {
+		opts = append(opts, wol.WithPassword(cliFlags.Password))
+	}
+	if cliFlags.Verbose > 0 {
+		opts = append(opts, wol.WithLogger(debugLog))
+	}
+
+	if cliFlags.IPv6 || cliFlags.TCP {
+		opts = append(opts, wol.WithNetwork(network()))
 	}
 
 	// bcastInterface can be "eth0", "eth1", etc.. An empty string implies
 	// that we use the default interface when sending the UDP packet (nil).
-	//bcastInterface := ""
-	macAddr := args[1]
+	if cliFlags.BroadcastInterface != "" {
+		opts = append(opts, wol.WithInterface(cliFlags.BroadcastInterface))
+	}
 
-	// Always use the interface specified in the command line, if it exists.
-	//if cliFlags.BroadcastInterface != "" {
-	//	bcastInterface = cliFlags.BroadcastInterface
-	//}
+	if cliFlags.Out != "" {
+		if len(targets) != 1 || len(badLines) != 0 {
+			return usageErrorf("-out needs a single MAC address to write a magic packet for")
+		}
+		return writePacketFile(cliFlags.Out, targets[0])
+	}
 
-	// Populate the local address in the event that the broadcast interface has
-	// been set.
-	var localAddr *net.UDPAddr
-	var err error
-	//if bcastInterface != "" {
-	//	localAddr, err = ipFromInterface(bcastInterface)
-	//	if err != nil {
-	//		return err
-	//	}
-	//}
+	if err := waitUntilScheduled(); err != nil {
+		return err
+	}
 
-	var broadcastIP = "255.255.255.255"
-	if len(args) > 2 {
-		broadcastIP = args[2]
+	if cliFlags.Retry > 0 && !cliFlags.DryRun {
+		if cliFlags.Wait == "" || len(targets) != 1 || len(badLines) != 0 {
+			return usageErrorf("-retry needs -wait and a single MAC address to wake")
+		}
+		return retryCmd(targets[0], broadcastIP, opts, aliases, banner)
 	}
-	// The address to broadcast to is usually the default `255.255.255.255` but
-	// can be overloaded by specifying an override in the CLI arguments.
-	bcastAddr := fmt.Sprintf("%s:%s", broadcastIP, "9")
-	udpAddr, err := net.ResolveUDPAddr("udp", bcastAddr)
+
+	if cliFlags.Daemon && !cliFlags.DryRun {
+		switch {
+		case len(targets) != 1 || len(badLines) != 0:
+			return usageErrorf("-daemon needs a single MAC address to wake")
+		case cliFlags.Repetitions != wol.DefaultRepetitions:
+			return usageErrorf("-daemon always sends the standard 16 repetitions, drop -repetitions")
+		case cliFlags.Tag != "":
+			return usageErrorf("-daemon always sends standard magic packets, drop -tag")
+		}
+		return daemonCmd(targets[0], broadcastIP, opts)
+	}
+
+	// Attempt every target before reporting, so that one bad entry does not
+	// stop the rest of the batch from being woken.
+	results := make([]result, 0, len(badLines)+len(targets))
+	for _, err := range badLines {
+		results = append(results, result{}.fail(err))
+	}
+	// An interrupt stops the batch, which is still reported as far as it got.
+	// A second one, while reporting, kills the process as usual.
+	ctx, stop := interruptContext()
+	start := time.Now()
+	results = append(results, wakeAll(ctx, targets, broadcastIP, opts)...)
+	elapsed := time.Since(start)
+	stop()
+	attempted := len(results) - len(badLines)
+	var metricsErr, historyErr error
+	if cliFlags.MetricsFile != "" {
+		metricsErr = writeMetrics(cliFlags.MetricsFile, results, elapsed)
+	}
+	if cliFlags.History != "" {
+		historyErr = appendHistory(cliFlags.History, results)
+	}
+	runHooks(results)
+	single := len(badLines)+len(targets) == 1 && cliFlags.File == "" && !fromStdin && !grouped
+	reportErr := reportResults(results, single, elapsed)
+	if attempted < len(targets) {
+		return fmt.Errorf("%w after attempting %d of %d MAC addresses", errInterrupted, attempted, len(targets))
+	}
+	if reportErr != nil {
+		return reportErr
+	}
+	if metricsErr != nil {
+		return fmt.Errorf("writing -metrics-file: %w", metricsErr)
+	}
+	if historyErr != nil {
+		return fmt.Errorf("writing -history: %w", historyErr)
+	}
+
+	if cliFlags.Wait != "" && !cliFlags.DryRun {
+		return waitCmd(cliFlags.Wait, aliases, banner)
+	}
+	return nil
+}
+
+// interfaceNameByIndex looks up the name of the interface with the index
+// given to -interface-index, which names it unambiguously even where an
+// interface is itself named like a number.
+func interfaceNameByIndex(index int) (string, error) {
+	if index < 1 {
+		return "", fmt.Errorf("-interface-index %d must be at least 1", index)
+	}
+	ief, err := net.InterfaceByIndex(index)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("-interface-index %d: %w", index, err)
 	}
+	return ief.Name, nil
+}
 
-	// Build the magic packet.
-	mp, err := MagicPacketNew(macAddr)
+// interfaceMAC returns the MAC address of the local interface named by -self.
+func interfaceMAC(name string) (string, error) {
+	ief, err := net.InterfaceByName(name)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("-self %s: %w", name, err)
 	}
+	if len(ief.HardwareAddr) == 0 {
+		return "", fmt.Errorf("-self %s: interface has no MAC address", name)
+	}
+	return ief.HardwareAddr.String(), nil
+}
 
-	// Grab a stream of bytes to send.
-	bs, err := mp.Marshal()
+// parsePorts parses the comma separated -ports list, whose items are ports or
+// inclusive ranges of them such as 7-9.
+func parsePorts(list string) ([]int, error) {
+	var ports []int
+	seen := map[int]bool{}
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		first, last, isRange := strings.Cut(field, "-")
+		from, err := parsePort(first)
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if isRange {
+			if to, err = parsePort(last); err != nil {
+				return nil, err
+			}
+			if to < from {
+				return nil, fmt.Errorf("-ports: range %s runs backwards", field)
+			}
+		}
+		for port := from; port <= to; port++ {
+			if seen[port] {
+				return nil, fmt.Errorf("-ports: port %d is listed twice", port)
+			}
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// parsePort parses a single port of the -ports list.
+func parsePort(field string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(field))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("-ports: port %q is not a number in the range 1-65535", field)
+	}
+	return port, nil
+}
+
+// splitWakeArgs splits the positional arguments of the wake command into the
+// targets to wake, the broadcast IP trailing them if any, and whether a "-"
+// asks for MAC addresses from stdin. otherTargets tells whether flags such as
+// -file name targets too, so that a single argument may be the broadcast IP.
+func splitWakeArgs(args []string, aliases map[string]target, otherTargets bool) (names []string, broadcastIP string, fromStdin bool, err error) {
+	lastJoined := false
+	for _, arg := range args {
+		if arg == "-" {
+			fromStdin = true
+			continue
+		}
+		// Tools which can pass only one argument may join several MAC
+		// addresses with commas.
+		lastJoined = strings.Contains(arg, ",")
+		for _, name := range strings.Split(arg, ",") {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	// Every argument is a MAC address or host alias, except for a trailing
+	// argument which looks like neither: that is the broadcast IP. It can't be
+	// part of a comma separated list though.
+	if !lastJoined && (len(names) > 1 || (len(names) == 1 && (otherTargets || fromStdin))) {
+		last := names[len(names)-1]
+		if _, ok := aliases[last]; !ok && !looksLikeMAC(last) && !strings.HasPrefix(last, "@") {
+			broadcastIP = last
+			names = names[:len(names)-1]
+		}
+	}
+
+	// Swapped arguments would otherwise fail as a confusing lookup error or
+	// an invalid MAC address.
+	for _, name := range names {
+		if _, ok := aliases[name]; !ok && net.ParseIP(name) != nil {
+			return nil, "", false, usageErrorf("%s is an IP address, not a MAC address: the broadcast IP goes after the MAC addresses, e.g. wol MAC_ADDRESS %s", name, name)
+		}
+	}
+	return names, broadcastIP, fromStdin, nil
+}
+
+// splitTarget splits the -target address into its host and port.
+func splitTarget(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("-target: %w", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	switch {
+	case host == "":
+		return "", 0, fmt.Errorf("-target %s has no host", addr)
+	case err != nil || port < 1 || port > 65535:
+		return "", 0, fmt.Errorf("-target %s: port %q is not a number in the range 1-65535", addr, portStr)
+	}
+	return host, port, nil
+}
+
+// waitHost resolves the -wait argument, an IP address, hostname or alias with an
+// IP, to the host to poll.
+func waitHost(host string, aliases map[string]target) (string, error) {
+	if t, ok := aliases[host]; ok {
+		if t.IP == "" {
+			return "", fmt.Errorf("alias %s has no ip= to wait for", host)
+		}
+		return t.IP, nil
+	}
+	return host, nil
+}
+
+// waitBanner compiles the -wait-banner pattern, returning nil without one.
+func waitBanner() (*regexp.Regexp, error) {
+	switch {
+	case cliFlags.WaitBanner == "":
+		return nil, nil
+	case cliFlags.Wait == "":
+		return nil, errors.New("-wait-banner needs -wait")
+	}
+	banner, err := regexp.Compile(cliFlags.WaitBanner)
+	if err != nil {
+		return nil, fmt.Errorf("-wait-banner: %w", err)
+	}
+	return banner, nil
+}
+
+// waitCmd polls host until it accepts TCP connections on the -wait-port, and
+// sends a first line matching banner if it isn't nil.
+func waitCmd(host string, aliases map[string]target, banner *regexp.Regexp) error {
+	host, err := waitHost(host, aliases)
 	if err != nil {
 		return err
 	}
+	addr := net.JoinHostPort(host, strconv.Itoa(cliFlags.WaitPort))
+
+	infof("Waiting up to %s for %s to come up\n", cliFlags.WaitTimeout, addr)
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), cliFlags.WaitTimeout)
+	defer cancel()
+	if err := wol.WaitForBanner(ctx, addr, time.Second, banner); err != nil {
+		return err
+	}
+
+	infof("Host %s confirmed up after %s\n", addr, time.Since(start).Round(time.Second))
+	return nil
+}
 
-	// Grab a UDP connection to send our packet of bytes.
-	conn, err := net.DialUDP("udp", localAddr, udpAddr)
+// retryCmd wakes t and waits for the -wait host to come up, resending the magic
+// packet -retry times, each time waiting twice as long as before.
+func retryCmd(t target, broadcastIP string, opts []wol.Option, aliases map[string]target, banner *regexp.Regexp) error {
+	host, err := waitHost(cliFlags.Wait, aliases)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
+	broadcastIP = t.destination(broadcastIP)
+	port := t.destinationPort()
 
-	fmt.Printf("Attempting to send a magic packet to MAC %s\n", macAddr)
-	fmt.Printf("... Broadcasting to: %s\n", bcastAddr)
-	n, err := conn.Write(bs)
-	if err == nil && n != 102 {
-		err = fmt.Errorf("magic packet sent was %d bytes (expected 102 bytes sent)", n)
+	res := result{
+		target:    t,
+		MAC:       t.canonicalMAC(),
+		Name:      t.Name,
+		Broadcast: broadcastIP,
+		Port:      port,
 	}
+	mp, err := buildPacket(t)
 	if err != nil {
 		return err
 	}
+	opts = append(opts[:len(opts):len(opts)],
+		wol.WithBroadcast(broadcastIP),
+		wol.WithPort(port),
+		wol.WithWaitPort(cliFlags.WaitPort),
+		wol.WithRetryDelay(cliFlags.RetryDelay),
+		wol.WithBanner(banner),
+		wol.WithOnSent(func(n int) {
+			res.BytesSent += n
+			res.packets += n / mp.Size()
+		}),
+	)
 
-	fmt.Printf("Magic packet sent successfully to %s\n", macAddr)
+	addr := net.JoinHostPort(host, strconv.Itoa(cliFlags.WaitPort))
+	infof("Waking %s until %s comes up, sending at most %d times\n", t, addr, cliFlags.Retry)
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), cliFlags.WaitTimeout)
+	defer cancel()
+	if err := wol.WakeUntilUpContext(ctx, t.MAC, host, cliFlags.Retry, opts...); err != nil {
+		res = res.fail(explainNetError(err))
+	} else {
+		res.Success = true
+		infof("Host %s confirmed up after %s\n", addr, time.Since(start).Round(time.Second))
+	}
+
+	runHooks([]result{res})
+	var historyErr error
+	if cliFlags.History != "" {
+		historyErr = appendHistory(cliFlags.History, []result{res})
+	}
+	if err := reportResults([]result{res}, true, time.Since(start)); err != nil {
+		return err
+	}
+	if historyErr != nil {
+		return fmt.Errorf("writing -history: %w", historyErr)
+	}
 	return nil
 }
 
-////////////////////////////////////////////////////////////////////////////////
+// network returns the network the magic packets are sent over, as picked by
+// -tcp and -6.
+func network() string {
+	n := "udp"
+	if cliFlags.TCP {
+		n = "tcp"
+	}
+	if cliFlags.IPv6 {
+		n += "6"
+	}
+	return n
+}
 
-func fatalOnError(err error) {
-	if err != nil {
-		fmt.Printf("Fatal error: %s\n", err.Error())
-		os.Exit(1)
+// warnedUnicast holds the destinations already warned about not being
+// broadcast addresses, so that a batch only warns once for each.
+var warnedUnicast = struct {
+	sync.Mutex
+	seen map[string]bool
+}{seen: map[string]bool{}}
+
+// destination returns the address to send the target's magic packet to. A
+// broadcastIP given on the command line takes precedence over the target's own
+// one, which takes precedence over the default.
+func (t target) destination(broadcastIP string) string {
+	if broadcastIP == "" {
+		broadcastIP = t.Broadcast
 	}
+	if broadcastIP == "" {
+		broadcastIP = defaultBroadcast()
+	}
+
+	// A -host or TCP relay is unicast on purpose, it rebroadcasts the packet.
+	if cliFlags.Host != "" || cliFlags.TCP {
+		return broadcastIP
+	}
+	warnedUnicast.Lock()
+	defer warnedUnicast.Unlock()
+	if ip := net.ParseIP(broadcastIP); ip != nil && !warnedUnicast.seen[broadcastIP] && !isBroadcastIP(ip) {
+		warnedUnicast.seen[broadcastIP] = true
+		warnf("%s is not a broadcast address, unicast wake-on-LAN only works if a static ARP entry exists for it (on this machine or the router)\n", broadcastIP)
+	}
+	return broadcastIP
 }
 
-// Main entry point for binary.
-func main() {
+// wakeAll wakes every target, -parallel of them at a time. Each target's
+// progress is printed in one piece once it is done, so that targets woken
+// concurrently don't interleave their messages, and the results keep the order
+// of targets.
+//
+// Once ctx is done no more targets are started, and the results only cover
+// those which were. The sends already under way get interruptGrace to finish.
+func wakeAll(ctx context.Context, targets []target, broadcastIP string, opts []wol.Option) []result {
+	sendCtx, cancel := graceContext(ctx, interruptGrace)
+	defer cancel()
 
-	fmt.Printf("Usage: wol MAC_ADDRESS [BROADCAST_IP]")
-	fmt.Printf("       wol 18-18-18-18-18-18 192.168.1.255")
-	fmt.Printf("       wol 18-18-18-18-18-18")
-	fmt.Printf("Note: BROADCAST_IP default is 255.255.255.255")
+	results := make([]result, len(targets))
+	if cliFlags.Parallel <= 1 {
+		for idx, t := range targets {
+			if ctx.Err() != nil {
+				return results[:idx]
+			}
+			results[idx] = wakeTarget(sendCtx, t, broadcastIP, opts, os.Stdout)
+		}
+		return results
+	}
+
+	started := make([]bool, len(targets))
+	jobs := make(chan int)
+	finished := make(chan *bytes.Buffer)
+	var wg sync.WaitGroup
+	for worker := 0; worker < cliFlags.Parallel; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				var out bytes.Buffer
+				results[idx] = wakeTarget(sendCtx, targets[idx], broadcastIP, opts, &out)
+				finished <- &out
+			}
+		}()
+	}
+	go func() {
+	feed:
+		for idx := range targets {
+			select {
+			case <-ctx.Done():
+				break feed
+			case jobs <- idx:
+				started[idx] = true
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(finished)
+	}()
+
+	for out := range finished {
+		os.Stdout.Write(out.Bytes())
+	}
 
+	attempted := results[:0]
+	for idx, res := range results {
+		if started[idx] {
+			attempted = append(attempted, res)
+		}
+	}
+	return attempted
+}
+
+// destinationPort returns the UDP port to send the target's magic packet to. A
+// -port given on the command line takes precedence over the target's own port,
+// which takes precedence over the default.
+func (t target) destinationPort() int {
+	if t.Port != 0 && !flagGiven("port") && cliFlags.Target == "" {
+		return t.Port
+	}
+	return cliFlags.Port
+}
+
+// buildPacket builds the magic packet for t, with the -password, -repetitions
+// and -tag.
+func buildPacket(t target) (*wol.MagicPacket, error) {
+	var mp *wol.MagicPacket
 	var err error
+	if cliFlags.Password != "" {
+		mp, err = wol.MagicPacketNewWithPassword(t.MAC, cliFlags.Password)
+	} else {
+		mp, err = wol.MagicPacketNew(t.MAC)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := mp.SetRepetitions(cliFlags.Repetitions); err != nil {
+		return nil, err
+	}
+	mp.SetTag([]byte(cliFlags.Tag))
+	return mp, nil
+}
+
+// writePacketFile writes the raw magic packet for t to path, or to stdout for
+// "-", instead of sending it.
+func writePacketFile(path string, t target) error {
+	mp, err := buildPacket(t)
+	if err != nil {
+		return err
+	}
+	bs, err := mp.Marshal()
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(bs)
+		return err
+	}
+	if err := os.WriteFile(path, bs, 0o644); err != nil {
+		return err
+	}
+	infof("Wrote %d byte magic packet for %s to %s\n", len(bs), t, path)
+	return nil
+}
 
-	err = wakeCmd(os.Args)
-	fatalOnError(err)
-	os.Exit(0)
+// wakeTarget sends a single magic packet to the target's destination and prints
+// its progress to out.
+func wakeTarget(ctx context.Context, t target, broadcastIP string, opts []wol.Option, out io.Writer) result {
+	broadcastIP = t.destination(broadcastIP)
+	port := t.destinationPort()
+
+	res := result{
+		target:    t,
+		MAC:       t.canonicalMAC(),
+		Name:      t.Name,
+		Broadcast: broadcastIP,
+		Port:      port,
+		Interface: t.Interface,
+	}
+
+	// The address to broadcast to is usually the default `255.255.255.255` but
+	// can be overloaded by specifying an override in the CLI arguments.
+	bcastAddr := net.JoinHostPort(broadcastIP, strconv.Itoa(port))
+	opts = append(opts[:len(opts):len(opts)], wol.WithBroadcast(broadcastIP), wol.WithPort(port))
+	iface := cliFlags.BroadcastInterface
+	if t.Interface != "" {
+		iface = t.Interface
+		opts = append(opts, wol.WithInterface(iface))
+	}
+
+	infoTo(out, "Attempting to send a magic packet to MAC %s\n", t)
+	infoTo(out, "... Broadcasting to: %s\n", bcastAddr)
+	if iface != "" {
+		infoTo(out, "... Using interface: %s\n", iface)
+	}
+
+	mp, err := buildPacket(t)
+	if err != nil {
+		return res.fail(err)
+	}
+	bs, err := mp.Marshal()
+	if err != nil {
+		return res.fail(err)
+	}
+	if cliFlags.Dump {
+		infoTo(out, "... Packet: %s\n", mp)
+	}
+	debugf(1, "%s: built %d byte magic packet for %s", t, len(bs), bcastAddr)
+	debugf(2, "%s: packet bytes:\n%s", t, hex.Dump(bs))
+
+	// A dry run stops short of the network, other than resolving the
+	// destination to show where the packet would have gone.
+	if cliFlags.DryRun {
+		if cliFlags.NoResolve && net.ParseIP(strings.SplitN(broadcastIP, "%", 2)[0]) == nil {
+			return res.fail(fmt.Errorf("%s is not an IP address, and name resolution is disabled", broadcastIP))
+		}
+		// TCP and UDP addresses resolve alike.
+		udpAddr, err := net.ResolveUDPAddr(strings.Replace(network(), "tcp", "udp", 1), bcastAddr)
+		if err != nil {
+			return res.fail(err)
+		}
+		res.DryRun = true
+		res.Success = true
+		infoTo(out, "Dry run: would send %d x %d byte magic packet to %s\n", cliFlags.Count, len(bs), udpAddr)
+		return res
+	}
+
+	if cliFlags.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cliFlags.Timeout)
+		defer cancel()
+	}
+	opts = append(opts, wol.WithOnConnect(func(local, _ net.Addr) {
+		res.Source = local.String()
+		debugf(1, "%s: sending from %s", t, local)
+	}))
+	sent, _, err := wol.Send(ctx, mp, opts...)
+	res.BytesSent = sent
+	res.packets = sent / mp.Size()
+	if err != nil {
+		return res.fail(explainNetError(err))
+	}
+	res.Success = true
+
+	if cliFlags.Count > 1 {
+		infoTo(out, "%d magic packets sent successfully to %s\n", cliFlags.Count, t)
+		return res
+	}
+	infoTo(out, "Magic packet sent successfully to %s\n", t)
+	return res
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// usage prints the command line help, including every supported flag, to w.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: wol [wake] [FLAGS] MAC_ADDRESS|ALIAS|@TAG... [BROADCAST_IP]")
+	fmt.Fprintln(w, "       wol 18-18-18-18-18-18 192.168.1.255")
+	fmt.Fprintln(w, "       wol 18-18-18-18-18-18")
+	fmt.Fprintln(w, "       cat macs.txt | wol -")
+	fmt.Fprintln(w, "       wol listen [-port PORT]")
+	fmt.Fprintln(w, "       wol list [-json]")
+	fmt.Fprintln(w, "       wol replay [-force] FILE [BROADCAST_IP]")
+	fmt.Fprintln(w, "       wol version")
+	fmt.Fprintln(w, "       wol completion bash|zsh|fish")
+	fmt.Fprintln(w, "Run `wol COMMAND -h` for the flags of listen, list, replay, version and completion.")
+	fmt.Fprintln(w, "Note: the broadcast IP is -broadcast, else BROADCAST_IP, else the alias' own one,")
+	fmt.Fprintln(w, "      else $WOL_BROADCAST, else 255.255.255.255. -port defaults to $WOL_PORT if set")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Exit status: 0 every target woken, 1 at least one target failed,")
+	fmt.Fprintln(w, "             2 bad command line, 3 sent but the -wait host never came up,")
+	fmt.Fprintln(w, "             4 interrupted by SIGINT or SIGTERM before every target was attempted")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+
+	flag.CommandLine.SetOutput(w)
+	flag.PrintDefaults()
+	flag.CommandLine.SetOutput(io.Discard)
+}
+
+// Main entry point for binary.
+func main() {
+	// Flag errors are reported below, so that asking for help (exit 0) can be
+	// told apart from a mistake on the command line (exit 2).
+	flag.CommandLine.Init("wol", flag.ContinueOnError)
+	flag.CommandLine.SetOutput(io.Discard)
+
+	// Anything but a subcommand name is waking, as it was before there were
+	// subcommands.
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			runCommand(cmd, os.Args[2:])
+		}
+	}
+	runCommand(commands["wake"], os.Args[1:])
 }
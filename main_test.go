@@ -0,0 +1,213 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestParseArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		want     []string
+		wantPort int
+		wantDump bool
+		wantErr  bool
+	}{
+		{args: nil, want: nil, wantPort: 9},
+		{args: []string{"18-18-18-18-18-18"}, want: []string{"18-18-18-18-18-18"}, wantPort: 9},
+		{args: []string{"-port", "7", "18-18-18-18-18-18"}, want: []string{"18-18-18-18-18-18"}, wantPort: 7},
+		{args: []string{"18-18-18-18-18-18", "-port", "7"}, want: []string{"18-18-18-18-18-18"}, wantPort: 7},
+		{args: []string{"18-18-18-18-18-18", "-port=7", "192.168.1.255"}, want: []string{"18-18-18-18-18-18", "192.168.1.255"}, wantPort: 7},
+		{args: []string{"a", "-dump", "b", "-port", "7", "c"}, want: []string{"a", "b", "c"}, wantPort: 7, wantDump: true},
+		{args: []string{"-dump", "--", "-port", "7"}, want: []string{"-port", "7"}, wantPort: 9, wantDump: true},
+		{args: []string{"a", "--", "-dump"}, want: []string{"a", "-dump"}, wantPort: 9},
+		{args: []string{"-"}, want: []string{"-"}, wantPort: 9},
+		{args: []string{"a", "-port"}, wantErr: true},
+		{args: []string{"a", "-port", "seven"}, wantErr: true},
+		{args: []string{"a", "-no-such-flag"}, wantErr: true},
+	} {
+		fs := flag.NewFlagSet("wol", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		port := fs.Int("port", 9, "")
+		dump := fs.Bool("dump", false, "")
+
+		got, err := parseArgs(fs, tc.args)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseArgs(%q) = %q, want an error", tc.args, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseArgs(%q) failed: %s", tc.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseArgs(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+		if *port != tc.wantPort || *dump != tc.wantDump {
+			t.Errorf("parseArgs(%q) set -port %d -dump %t, want %d %t", tc.args, *port, *dump, tc.wantPort, tc.wantDump)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{err: nil, want: exitOK},
+		{err: errors.New("failed to wake 1 of 2 MAC addresses"), want: exitFailed},
+		{err: usageErrorf("both -interface and -interface-index specified"), want: exitUsage},
+		{err: fmt.Errorf("wrapped: %w", usageErrorf("-out needs a single MAC address")), want: exitUsage},
+		{err: &wol.NotUpError{Addr: "192.168.1.10:22", Attempts: 3}, want: exitNotUp},
+		{err: fmt.Errorf("%w after attempting 1 of 2 MAC addresses", errInterrupted), want: exitSignal},
+	} {
+		if got := exitCode(tc.err); got != tc.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestSplitTarget(t *testing.T) {
+	for _, tc := range []struct {
+		addr     string
+		wantHost string
+		wantPort int
+		wantErr  bool
+	}{
+		{addr: "192.168.1.255:9", wantHost: "192.168.1.255", wantPort: 9},
+		{addr: "relay.example.com:40000", wantHost: "relay.example.com", wantPort: 40000},
+		{addr: "[ff02::1]:9", wantHost: "ff02::1", wantPort: 9},
+		{addr: "[ff02::1%eth0]:7", wantHost: "ff02::1%eth0", wantPort: 7},
+		{addr: "192.168.1.255", wantErr: true},
+		{addr: "ff02::1", wantErr: true},
+		{addr: "ff02::1:9", wantErr: true},
+		{addr: "192.168.1.255:", wantErr: true},
+		{addr: ":9", wantErr: true},
+		{addr: "192.168.1.255:nine", wantErr: true},
+		{addr: "192.168.1.255:0", wantErr: true},
+		{addr: "192.168.1.255:65536", wantErr: true},
+		{addr: "[ff02::1]:-1", wantErr: true},
+	} {
+		host, port, err := splitTarget(tc.addr)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("splitTarget(%q) = %q, %d, want an error", tc.addr, host, port)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitTarget(%q) failed: %s", tc.addr, err)
+			continue
+		}
+		if host != tc.wantHost || port != tc.wantPort {
+			t.Errorf("splitTarget(%q) = %q, %d, want %q, %d", tc.addr, host, port, tc.wantHost, tc.wantPort)
+		}
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	for _, tc := range []struct {
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{list: "9", want: []int{9}},
+		{list: "7,9", want: []int{7, 9}},
+		{list: "9, 7", want: []int{9, 7}},
+		{list: "7-9", want: []int{7, 8, 9}},
+		{list: "9,7-8", want: []int{9, 7, 8}},
+		{list: "9-9", want: []int{9}},
+		{list: "1,65535", want: []int{1, 65535}},
+		{list: "7,9,7", wantErr: true},
+		{list: "7-9,8", wantErr: true},
+		{list: "0", wantErr: true},
+		{list: "0-9", wantErr: true},
+		{list: "65536", wantErr: true},
+		{list: "9-65536", wantErr: true},
+		{list: "9-7", wantErr: true},
+		{list: "7-", wantErr: true},
+		{list: "-9", wantErr: true},
+		{list: "7,,9", wantErr: true},
+		{list: "seven", wantErr: true},
+	} {
+		got, err := parsePorts(tc.list)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parsePorts(%q) = %v, want an error", tc.list, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePorts(%q) failed: %s", tc.list, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parsePorts(%q) = %v, want %v", tc.list, got, tc.want)
+		}
+	}
+}
+
+func TestSplitWakeArgs(t *testing.T) {
+	aliases := map[string]target{
+		"nas":          {Name: "nas", MAC: "18:18:18:18:18:18"},
+		"192.168.1.10": {Name: "192.168.1.10", MAC: "18:18:18:18:18:19"},
+	}
+	for _, tc := range []struct {
+		args          []string
+		otherTargets  bool
+		wantNames     []string
+		wantBroadcast string
+		wantStdin     bool
+		wantErr       bool
+	}{
+		{args: []string{"181818181818"}, wantNames: []string{"181818181818"}},
+		{args: []string{"181818181818", "192.168.1.255"}, wantNames: []string{"181818181818"}, wantBroadcast: "192.168.1.255"},
+		{args: []string{"181818181818", "181818181819"}, wantNames: []string{"181818181818", "181818181819"}},
+		{args: []string{"nas", "@lab", "255.255.255.255"}, wantNames: []string{"nas", "@lab"}, wantBroadcast: "255.255.255.255"},
+		{args: []string{"181818181818,181818181819"}, wantNames: []string{"181818181818", "181818181819"}},
+		{args: []string{"181818181818,nas,", "192.168.1.255"}, wantNames: []string{"181818181818", "nas"}, wantBroadcast: "192.168.1.255"},
+		{args: []string{",181818181818,,181818181819,"}, wantNames: []string{"181818181818", "181818181819"}},
+		{args: []string{","}, wantNames: nil},
+		// The last item of a comma separated list is still a target.
+		{args: []string{"181818181818,relay.example.com"}, wantNames: []string{"181818181818", "relay.example.com"}},
+		// A lone argument is a target, unless other flags name the targets.
+		{args: []string{"relay.example.com"}, wantNames: []string{"relay.example.com"}},
+		{args: []string{"192.168.1.255"}, otherTargets: true, wantNames: []string{}, wantBroadcast: "192.168.1.255"},
+		{args: []string{"-", "192.168.1.255"}, wantNames: []string{}, wantBroadcast: "192.168.1.255", wantStdin: true},
+		{args: []string{"-"}, wantStdin: true},
+		// An alias may be named like an IP address.
+		{args: []string{"192.168.1.10"}, wantNames: []string{"192.168.1.10"}},
+		// Swapped arguments.
+		{args: []string{"192.168.1.255", "181818181818"}, wantErr: true},
+		{args: []string{"192.168.1.255"}, wantErr: true},
+		{args: []string{"181818181818,192.168.1.255"}, wantErr: true},
+		{args: []string{"ff02::1", "181818181818"}, wantErr: true},
+	} {
+		names, broadcastIP, fromStdin, err := splitWakeArgs(tc.args, aliases, tc.otherTargets)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("splitWakeArgs(%q) = %q, %q, want an error", tc.args, names, broadcastIP)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitWakeArgs(%q) failed: %s", tc.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(names, tc.wantNames) || broadcastIP != tc.wantBroadcast || fromStdin != tc.wantStdin {
+			t.Errorf("splitWakeArgs(%q) = %q, %q, %t, want %q, %q, %t", tc.args, names, broadcastIP, fromStdin, tc.wantNames, tc.wantBroadcast, tc.wantStdin)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// writeMetrics writes counters for a run to path in the Prometheus text
+// format, for node_exporter's textfile collector to pick up. The file is
+// replaced atomically, so that the collector never reads half of it.
+func writeMetrics(path string, results []result, elapsed time.Duration) error {
+	sum := summarize(results, elapsed)
+	packets, bytesSent := 0, 0
+	for _, res := range results {
+		packets += res.packets
+		bytesSent += res.BytesSent
+	}
+
+	var buf bytes.Buffer
+	metric := func(name, help, kind string, value interface{}) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+	metric("wol_wake_attempts_total", "MAC addresses the last run tried to wake.", "counter", sum.Attempted)
+	metric("wol_wake_failures_total", "MAC addresses the last run failed to wake.", "counter", sum.Failed)
+	metric("wol_packets_sent_total", "Magic packets sent by the last run.", "counter", packets)
+	metric("wol_bytes_sent_total", "Bytes of magic packets sent by the last run.", "counter", bytesSent)
+	metric("wol_last_run_duration_seconds", "How long the last run took to send.", "gauge", sum.Elapsed)
+	metric("wol_last_run_timestamp_seconds", "When the last run finished, in seconds since the epoch.", "gauge", time.Now().Unix())
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// CreateTemp makes the file private, the collector may run as another user.
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
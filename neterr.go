@@ -0,0 +1,41 @@
+//go:build !plan9
+
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// explainNetError adds a hint on what to do about the common reasons sending
+// fails, which the OS only reports as a terse syscall error. Any other error
+// is returned as is.
+func explainNetError(err error) error {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return err
+	}
+
+	var hint string
+	switch {
+	case errors.Is(err, wol.ErrWriteTimeout):
+		hint = "the socket didn't accept the packet in time, check the network interface or allow longer with -timeout"
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+		hint = "there is no route to the broadcast address, pick the interface on that network with -interface or check the broadcast IP"
+	case errors.Is(err, syscall.ENETDOWN):
+		hint = "the network interface is down, bring it up or pick another with -interface"
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		hint = "broadcasting was refused, check that a firewall or container sandbox permits it, or send to a directed broadcast such as -cidr 192.168.1.0/24"
+	default:
+		return err
+	}
+	return fmt.Errorf("%w (%s)", err, hint)
+}
@@ -0,0 +1,9 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+// explainNetError returns err as is, Plan 9 reports network errors as strings
+// rather than the errno values recognized elsewhere.
+func explainNetError(err error) error {
+	return err
+}
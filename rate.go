@@ -0,0 +1,66 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"context"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// rateLimiter paces the packets of a whole run, across every -parallel worker,
+// to -rate per second. It is a token bucket holding a single token, so that an
+// idle limiter lets the next packet go at once but never a burst.
+type rateLimiter struct {
+	tokens chan struct{}
+	done   chan struct{}
+}
+
+// newRateLimiter returns a limiter allowing perSecond packets a second, which
+// must be stopped once done with.
+func newRateLimiter(perSecond float64) *rateLimiter {
+	l := &rateLimiter{
+		tokens: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	l.tokens <- struct{}{}
+
+	// Rates beyond a packet a nanosecond are as good as unlimited, but still
+	// need a positive interval.
+	interval := time.Duration(float64(time.Second) / perSecond)
+	if interval < 1 {
+		interval = 1
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case l.tokens <- struct{}{}:
+				default:
+				}
+			case <-l.done:
+				return
+			}
+		}
+	}()
+	return l
+}
+
+// wait blocks until the next packet may be sent, or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	select {
+	case <-l.tokens:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop releases the limiter's ticker.
+func (l *rateLimiter) stop() {
+	close(l.done)
+}
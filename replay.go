@@ -0,0 +1,71 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// replayFlags adds the flags of `wol replay`.
+func replayFlags(fs *flag.FlagSet) {
+	fs.IntVar(&cliFlags.Port, "port", wol.DefaultPort, "UDP port to send to")
+	fs.StringVar(&cliFlags.BroadcastInterface, "interface", "", "network interface to send from, by name (default picked by the OS)")
+	fs.IntVar(&cliFlags.Count, "count", 1, "number of times to send the packet")
+	fs.DurationVar(&cliFlags.Interval, "interval", 100*time.Millisecond, "delay between repeated sends with -count")
+	fs.BoolVar(&cliFlags.Force, "force", false, "send the file even if it isn't a standard magic packet")
+}
+
+// replayCmd sends the bytes of a file written by -out, or any other, verbatim
+// to the broadcast IP. Unless -force is given, the file has to hold a magic
+// packet.
+func replayCmd(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("expected FILE [BROADCAST_IP], got %q", args)
+	}
+	if err := applyEnvDefaults(); err != nil {
+		return err
+	}
+
+	bs, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	if !cliFlags.Force {
+		if _, err := wol.MagicPacketUnmarshal(bs); err != nil {
+			return fmt.Errorf("%s: %w (send it anyway with -force)", args[0], err)
+		}
+	}
+
+	broadcastIP := defaultBroadcast()
+	if len(args) == 2 {
+		broadcastIP = args[1]
+	}
+	opts := []wol.Option{
+		wol.WithBroadcast(broadcastIP),
+		wol.WithPort(cliFlags.Port),
+		wol.WithCount(cliFlags.Count),
+		wol.WithInterval(cliFlags.Interval),
+	}
+	if cliFlags.BroadcastInterface != "" {
+		opts = append(opts, wol.WithInterface(cliFlags.BroadcastInterface))
+	}
+	if cliFlags.Verbose > 0 {
+		opts = append(opts, wol.WithLogger(debugLog))
+	}
+
+	if _, _, err := wol.SendBytes(context.Background(), bs, opts...); err != nil {
+		return explainNetError(err)
+	}
+	infof("Replayed %d bytes from %s to %s\n", len(bs), args[0], net.JoinHostPort(broadcastIP, strconv.Itoa(cliFlags.Port)))
+	return nil
+}
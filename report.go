@@ -0,0 +1,180 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"wol/wol"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// result is the outcome of waking a single target, or of an input line which
+// could not be parsed into one.
+type result struct {
+	MAC       string `json:"mac"`
+	Name      string `json:"name,omitempty"`
+	Broadcast string `json:"broadcast,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	Interface string `json:"interface,omitempty"`
+	Source    string `json:"source,omitempty"`
+	BytesSent int    `json:"bytes_sent"`
+	DryRun    bool   `json:"dry_run,omitempty"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+	Code      string `json:"code,omitempty"`
+
+	target  target
+	err     error
+	packets int
+}
+
+// fail records err as the reason the target could not be woken.
+func (r result) fail(err error) result {
+	r.Success = false
+	r.Error = err.Error()
+	r.Code = wol.ErrorCode(err)
+	r.err = err
+	return r
+}
+
+// String describes the result for the batch summary.
+func (r result) String() string {
+	switch {
+	case r.target.MAC == "":
+		return fmt.Sprintf("skipped %s", r.err)
+	case r.err != nil:
+		return fmt.Sprintf("%s: failed: %s", r.target, r.err)
+	}
+	return fmt.Sprintf("%s: ok", r.target)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// infof prints a progress message, unless the output is meant for scripts or
+// has been silenced with -quiet.
+func infof(format string, args ...interface{}) {
+	infoTo(os.Stdout, format, args...)
+}
+
+// infoTo is like infof, but prints the message to w.
+func infoTo(w io.Writer, format string, args ...interface{}) {
+	if cliFlags.JSON || cliFlags.Quiet {
+		return
+	}
+	fmt.Fprintf(w, format, args...)
+}
+
+// warnf prints a warning to stderr, where it doesn't get mixed up with -json
+// output, unless it has been silenced with -quiet.
+func warnf(format string, args ...interface{}) {
+	if cliFlags.Quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: "+format, args...)
+}
+
+// debugLog receives the -v logging, timestamped and kept apart from the normal
+// output on stdout.
+var debugLog = log.New(os.Stderr, "", log.LstdFlags|log.Lmicroseconds)
+
+// debugf logs a step of the wake command when -v was given at least level
+// times.
+func debugf(level int, format string, args ...interface{}) {
+	if int(cliFlags.Verbose) < level {
+		return
+	}
+	debugLog.Printf(format, args...)
+}
+
+// summary totals up a batch, so that large runs can be audited at a glance.
+type summary struct {
+	Attempted int     `json:"attempted"`
+	Succeeded int     `json:"succeeded"`
+	Failed    int     `json:"failed"`
+	Elapsed   float64 `json:"elapsed_seconds"`
+
+	elapsed time.Duration
+}
+
+// summarize totals up results, which took elapsed to send.
+func summarize(results []result, elapsed time.Duration) summary {
+	sum := summary{
+		Attempted: len(results),
+		Elapsed:   elapsed.Seconds(),
+		elapsed:   elapsed,
+	}
+	for _, res := range results {
+		if res.err != nil {
+			sum.Failed++
+		} else {
+			sum.Succeeded++
+		}
+	}
+	return sum
+}
+
+// String describes the summary in a line.
+func (s summary) String() string {
+	return fmt.Sprintf("%d attempted, %d succeeded, %d failed in %s", s.Attempted, s.Succeeded, s.Failed, s.elapsed.Round(time.Millisecond))
+}
+
+// reportResults prints the outcome of the wake command and returns an error if
+// any target failed. A single target is reported by its own error, a batch by
+// its per-target results and a summary of how long they took.
+func reportResults(results []result, single bool, elapsed time.Duration) error {
+	// An interrupt can stop even a single target before it is sent, leaving
+	// only an empty summary to report.
+	if len(results) == 0 {
+		results, single = []result{}, false
+	}
+	sum := summarize(results, elapsed)
+	failed := sum.Failed
+
+	switch {
+	case cliFlags.JSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		var err error
+		if single {
+			err = enc.Encode(results[0])
+		} else {
+			err = enc.Encode(struct {
+				Results []result `json:"results"`
+				Summary summary  `json:"summary"`
+			}{results, sum})
+		}
+		if err != nil {
+			return err
+		}
+	case single:
+		// The progress messages and returned error say it all.
+	case cliFlags.Quiet:
+		// Only the failures are worth mentioning, and those go to stderr.
+		for _, res := range results {
+			if res.err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", res)
+			}
+		}
+	default:
+		fmt.Printf("Results:\n")
+		for _, res := range results {
+			fmt.Printf("... %s\n", res)
+		}
+		fmt.Printf("Summary: %s\n", sum)
+	}
+
+	switch {
+	case failed == 0:
+		return nil
+	case single:
+		return results[0].err
+	}
+	return fmt.Errorf("failed to wake %d of %d MAC addresses", failed, len(results))
+}
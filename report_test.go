@@ -0,0 +1,44 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestReportResultsEmpty(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	defer func(saved *os.File) { os.Stdout = saved }(os.Stdout)
+	os.Stdout = out
+	defer func(saved bool) { cliFlags.JSON = saved }(cliFlags.JSON)
+	cliFlags.JSON = true
+
+	// Interrupted before the only target was sent.
+	if err := reportResults(nil, true, 0); err != nil {
+		t.Errorf("reportResults(nil) = %v, want nil", err)
+	}
+
+	bs, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Results []result `json:"results"`
+		Summary summary  `json:"summary"`
+	}
+	if err := json.Unmarshal(bs, &report); err != nil {
+		t.Fatalf("-json output %q: %s", bs, err)
+	}
+	if report.Results == nil || len(report.Results) != 0 || report.Summary.Attempted != 0 {
+		t.Errorf("-json output = %s, want no results and an empty summary", bs)
+	}
+}
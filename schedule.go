@@ -0,0 +1,82 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// scheduledTime returns when -at or -after asks for the magic packets to be
+// sent, relative to now, or the zero time to send them straight away. An -at
+// time which has already passed today is either moved to tomorrow or rejected,
+// depending on -at-past.
+func scheduledTime(now time.Time) (time.Time, error) {
+	switch {
+	case cliFlags.At != "" && cliFlags.After != 0:
+		return time.Time{}, errors.New("both -at and -after specified")
+	case cliFlags.After < 0:
+		return time.Time{}, fmt.Errorf("-after %s must not be negative", cliFlags.After)
+	case cliFlags.After > 0:
+		return now.Add(cliFlags.After), nil
+	case cliFlags.At == "":
+		return time.Time{}, nil
+	}
+
+	var clock time.Time
+	var err error
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if clock, err = time.Parse(layout, cliFlags.At); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("-at %s is not a time of day (expected HH:MM or HH:MM:SS)", cliFlags.At)
+	}
+
+	if cliFlags.AtPast != "tomorrow" && cliFlags.AtPast != "error" {
+		return time.Time{}, fmt.Errorf("unsupported -at-past %q (expected tomorrow or error)", cliFlags.AtPast)
+	}
+
+	at := clockOn(now, clock)
+	if at.After(now) {
+		return at, nil
+	}
+	if cliFlags.AtPast == "error" {
+		return time.Time{}, fmt.Errorf("-at %s has already passed today", cliFlags.At)
+	}
+	return clockOn(now.AddDate(0, 0, 1), clock), nil
+}
+
+// clockOn returns the time of day of clock on the date of day, in its location.
+// A time skipped by the clocks going forward, such as 02:30 on the night
+// daylight saving time starts, is moved on by as much as the clocks jumped.
+func clockOn(day, clock time.Time) time.Time {
+	at := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, day.Location())
+	if at.Hour() != clock.Hour() || at.Minute() != clock.Minute() {
+		_, before := at.Zone()
+		_, after := at.Add(24 * time.Hour).Zone()
+		at = at.Add(time.Duration(after-before) * time.Second)
+	}
+	return at
+}
+
+// waitUntilScheduled sleeps until the time asked for by -at or -after, if any.
+// A dry run only reports when the packets would have been sent.
+func waitUntilScheduled() error {
+	at, err := scheduledTime(time.Now())
+	if err != nil || at.IsZero() {
+		return err
+	}
+
+	if cliFlags.DryRun {
+		infof("Dry run: would wait until %s to send\n", at.Format(time.RFC1123))
+		return nil
+	}
+	infof("Waiting until %s to send\n", at.Format(time.RFC1123))
+	time.Sleep(time.Until(at))
+	return nil
+}
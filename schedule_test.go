@@ -0,0 +1,118 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"testing"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestScheduledTime(t *testing.T) {
+	defer func(at, atPast string, after time.Duration) {
+		cliFlags.At, cliFlags.AtPast, cliFlags.After = at, atPast, after
+	}(cliFlags.At, cliFlags.AtPast, cliFlags.After)
+
+	utc := time.UTC
+	now := time.Date(2024, 3, 9, 12, 30, 0, 0, utc)
+	for _, tc := range []struct {
+		at      string
+		atPast  string
+		after   time.Duration
+		want    time.Time
+		wantErr bool
+	}{
+		{want: time.Time{}},
+		{after: 30 * time.Minute, want: now.Add(30 * time.Minute)},
+		{after: -time.Minute, wantErr: true},
+		{at: "07:00", after: time.Minute, wantErr: true},
+		{at: "13:00", want: time.Date(2024, 3, 9, 13, 0, 0, 0, utc)},
+		{at: "13:00:15", want: time.Date(2024, 3, 9, 13, 0, 15, 0, utc)},
+		{at: "07:00", want: time.Date(2024, 3, 10, 7, 0, 0, 0, utc)},
+		{at: "12:30", want: time.Date(2024, 3, 10, 12, 30, 0, 0, utc)},
+		{at: "07:00", atPast: "error", wantErr: true},
+		{at: "13:00", atPast: "error", want: time.Date(2024, 3, 9, 13, 0, 0, 0, utc)},
+		{at: "07:00", atPast: "yesterday", wantErr: true},
+		{at: "7am", wantErr: true},
+		{at: "25:00", wantErr: true},
+		{at: "07:00:00:00", wantErr: true},
+		{at: "", atPast: "error", want: time.Time{}},
+	} {
+		cliFlags.At, cliFlags.After = tc.at, tc.after
+		cliFlags.AtPast = tc.atPast
+		if cliFlags.AtPast == "" {
+			cliFlags.AtPast = "tomorrow"
+		}
+
+		got, err := scheduledTime(now)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("scheduledTime(-at %q -at-past %q -after %s) = %s, want an error", tc.at, tc.atPast, tc.after, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("scheduledTime(-at %q -at-past %q -after %s) failed: %s", tc.at, tc.atPast, tc.after, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("scheduledTime(-at %q -at-past %q -after %s) = %s, want %s", tc.at, tc.atPast, tc.after, got, tc.want)
+		}
+	}
+}
+
+func TestScheduledTimeDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %s", err)
+	}
+	defer func(at, atPast string, after time.Duration) {
+		cliFlags.At, cliFlags.AtPast, cliFlags.After = at, atPast, after
+	}(cliFlags.At, cliFlags.AtPast, cliFlags.After)
+	cliFlags.AtPast, cliFlags.After = "tomorrow", 0
+
+	for _, tc := range []struct {
+		now  time.Time
+		at   string
+		want time.Time
+	}{
+		// Rolling over into the day the clocks go forward keeps the wall clock
+		// time, although the day is only 23 hours long.
+		{
+			now:  time.Date(2024, 3, 9, 12, 0, 0, 0, loc),
+			at:   "07:00",
+			want: time.Date(2024, 3, 10, 7, 0, 0, 0, loc),
+		},
+		// And likewise into the 25 hour day the clocks go back.
+		{
+			now:  time.Date(2024, 11, 2, 12, 0, 0, 0, loc),
+			at:   "07:00",
+			want: time.Date(2024, 11, 3, 7, 0, 0, 0, loc),
+		},
+		// A time skipped by the clocks going forward is sent an hour later.
+		{
+			now:  time.Date(2024, 3, 10, 1, 0, 0, 0, loc),
+			at:   "02:30",
+			want: time.Date(2024, 3, 10, 3, 30, 0, 0, loc),
+		},
+		{
+			now:  time.Date(2024, 3, 9, 3, 0, 0, 0, loc),
+			at:   "02:30",
+			want: time.Date(2024, 3, 10, 3, 30, 0, 0, loc),
+		},
+	} {
+		cliFlags.At = tc.at
+		got, err := scheduledTime(tc.now)
+		if err != nil {
+			t.Errorf("scheduledTime(%s, -at %s) failed: %s", tc.now, tc.at, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("scheduledTime(%s, -at %s) = %s, want %s", tc.now, tc.at, got, tc.want)
+		}
+		if got.Location() != loc {
+			t.Errorf("scheduledTime(%s, -at %s) is in %s, want %s", tc.now, tc.at, got.Location(), loc)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Build information, set at link time:
+//
+//	go build -ldflags "-X main.version=1.0.0 -X main.commit=$(git rev-parse --short HEAD) -X main.date=$(date -u +%Y-%m-%d)"
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+// versionString describes the running build, e.g. "wol 1.0.0 (commit 641bcdf, built 2022-09-23)".
+func versionString() string {
+	s := fmt.Sprintf("wol %s", version)
+	switch {
+	case commit != "" && date != "":
+		s += fmt.Sprintf(" (commit %s, built %s)", commit, date)
+	case commit != "":
+		s += fmt.Sprintf(" (commit %s)", commit)
+	case date != "":
+		s += fmt.Sprintf(" (built %s)", date)
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// arpEntry is a single IP -> MAC mapping from the system ARP cache.
+type arpEntry struct {
+	ip  net.IP
+	mac MACAddress
+}
+
+// LookupMAC returns the MAC address the system ARP cache holds for ip, so a
+// machine can be woken by its last known IP address. The cache only knows
+// about hosts which have recently been reachable on the local segment.
+func LookupMAC(ip string) (MACAddress, error) {
+	want := net.ParseIP(ip)
+	if want == nil {
+		return MACAddress{}, fmt.Errorf("%s is not an IP address", ip)
+	}
+
+	entries, err := arpTable()
+	if err != nil {
+		return MACAddress{}, err
+	}
+	for _, entry := range entries {
+		if entry.ip.Equal(want) {
+			return entry.mac, nil
+		}
+	}
+	return MACAddress{}, newError(ErrNotInARPCache, nil, "%s is not in the ARP cache (reach it once while it is awake, e.g. with ping)", ip)
+}
+
+// parseARPOutput extracts the complete entries from the output of `arp -a`,
+// whose layout differs between platforms:
+//
+//	? (192.168.1.1) at 0:11:22:33:44:55 on en0 ifscope [ethernet]
+//	  192.168.1.1           00-11-22-33-44-55     dynamic
+func parseARPOutput(r io.Reader) ([]arpEntry, error) {
+	var entries []arpEntry
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var entry arpEntry
+		var haveMAC bool
+		for _, field := range strings.Fields(scanner.Text()) {
+			if entry.ip == nil {
+				entry.ip = net.ParseIP(strings.Trim(field, "()"))
+				continue
+			}
+			if mac, ok := parseARPMAC(field); ok {
+				entry.mac = mac
+				haveMAC = true
+				break
+			}
+		}
+		if entry.ip != nil && haveMAC {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// parseARPMAC parses a MAC address from an ARP table, where octets may not be
+// zero padded (BSD prints 0:11:22:33:44:55). Incomplete all-zero entries are
+// not considered valid.
+func parseARPMAC(s string) (MACAddress, bool) {
+	var mac MACAddress
+
+	octets := strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune(delims, r)
+	})
+	if len(octets) != len(mac) {
+		return mac, false
+	}
+	for idx, octet := range octets {
+		if len(octet) == 1 {
+			octet = "0" + octet
+		}
+		b, err := hex.DecodeString(octet)
+		if err != nil || len(b) != 1 {
+			return mac, false
+		}
+		mac[idx] = b[0]
+	}
+	return mac, mac != MACAddress{}
+}
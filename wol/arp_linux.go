@@ -0,0 +1,44 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// arpTable reads the kernel's ARP cache from /proc/net/arp:
+//
+//	IP address       HW type     Flags       HW address            Mask     Device
+//	192.168.1.1      0x1         0x2         00:11:22:33:44:55     *        eth0
+func arpTable() ([]arpEntry, error) {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []arpEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		mac, ok := parseARPMAC(fields[3])
+		if ip == nil || !ok {
+			continue
+		}
+		entries = append(entries, arpEntry{ip: ip, mac: mac})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
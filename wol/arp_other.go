@@ -0,0 +1,22 @@
+//go:build !linux
+
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"os/exec"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// arpTable reads the ARP cache by running `arp -a`, as there is no portable
+// way to query it directly.
+func arpTable() ([]arpEntry, error) {
+	out, err := exec.Command("arp", "-a").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseARPOutput(bytes.NewReader(out))
+}
@@ -0,0 +1,39 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestParseARPOutput(t *testing.T) {
+	out := strings.Join([]string{
+		"? (192.168.1.1) at 0:11:22:a:44:55 on en0 ifscope [ethernet]",
+		"? (192.168.1.7) at (incomplete) on en0 ifscope [ethernet]",
+		"Interface: 192.168.1.20 --- 0xb",
+		"  Internet Address      Physical Address      Type",
+		"  192.168.1.50          00-1a-2b-3c-4d-5e     dynamic",
+	}, "\n")
+
+	entries, err := parseARPOutput(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []arpEntry{
+		{ip: net.ParseIP("192.168.1.1"), mac: MACAddress{0x00, 0x11, 0x22, 0x0a, 0x44, 0x55}},
+		{ip: net.ParseIP("192.168.1.50"), mac: MACAddress{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("parseARPOutput returned %d entries, want %d: %v", len(entries), len(want), entries)
+	}
+	for idx := range want {
+		if !entries[idx].ip.Equal(want[idx].ip) || entries[idx].mac != want[idx].mac {
+			t.Errorf("entry %d = %v %v, want %v %v", idx, entries[idx].ip, entries[idx].mac, want[idx].ip, want[idx].mac)
+		}
+	}
+}
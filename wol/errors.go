@@ -0,0 +1,180 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Sentinel errors for the common failures, to be tested for with errors.Is.
+// The errors actually returned carry a more detailed message, and unwrap to
+// the underlying cause where there is one.
+var (
+	// ErrInvalidMAC is returned for a string which isn't a MAC-48 address in
+	// one of the accepted forms.
+	ErrInvalidMAC = errors.New("invalid MAC address")
+
+	// ErrNotMAC48 is returned for a hardware address of the wrong length, such
+	// as an EUI-64. It also matches ErrInvalidMAC.
+	ErrNotMAC48 = fmt.Errorf("%w: not a 6 byte MAC-48 address", ErrInvalidMAC)
+
+	// ErrReservedMAC is returned for the all-zero and broadcast MAC addresses,
+	// which no machine can be woken by. It also matches ErrInvalidMAC.
+	ErrReservedMAC = fmt.Errorf("%w: reserved MAC address", ErrInvalidMAC)
+
+	// ErrNotEUI64 is returned for an identifier which isn't an EUI-64 derived
+	// from a MAC-48 address.
+	ErrNotEUI64 = errors.New("not a MAC derived EUI-64 identifier")
+
+	// ErrInvalidPassword is returned for a malformed SecureOn password.
+	ErrInvalidPassword = errors.New("invalid SecureOn password")
+
+	// ErrInvalidPacket is returned by MagicPacketUnmarshal for data which isn't
+	// a magic packet.
+	ErrInvalidPacket = errors.New("invalid magic packet")
+
+	// ErrInterfaceNotFound is returned for a WithInterface which names no
+	// local network interface.
+	ErrInterfaceNotFound = errors.New("network interface not found")
+
+	// ErrNoInterfaceAddress is returned when a network interface has no IPv4
+	// address to send from.
+	ErrNoInterfaceAddress = errors.New("no usable interface address")
+
+	// ErrLoopback is returned for the loopback broadcast address or a loopback
+	// WithInterface, neither of which reaches another machine.
+	ErrLoopback = errors.New("loopback never reaches another machine")
+
+	// ErrNotInARPCache is returned by LookupMAC for an IP address the system
+	// ARP cache doesn't know.
+	ErrNotInARPCache = errors.New("not in the ARP cache")
+
+	// ErrResolveFailed is returned when the destination host can't be
+	// resolved to an address.
+	ErrResolveFailed = errors.New("resolving the destination failed")
+
+	// ErrDialFailed is returned when no socket to the destination could be
+	// opened.
+	ErrDialFailed = errors.New("opening a connection failed")
+
+	// ErrWriteTimeout is returned when writing a magic packet blocks for longer
+	// than WithWriteTimeout or the context allows.
+	ErrWriteTimeout = errors.New("magic packet write timed out")
+
+	// ErrShortWrite is matched by the *ShortWriteError returned when a magic
+	// packet was only partly written.
+	ErrShortWrite = errors.New("magic packet only partly sent")
+
+	// ErrBannerMismatch is returned when a host accepts connections, but the
+	// first line it sends doesn't match WithBanner or WaitForBanner.
+	ErrBannerMismatch = errors.New("service banner doesn't match")
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// errorCodes are the codes ErrorCode returns for each sentinel error, the more
+// specific sentinels first.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrReservedMAC, "reserved-mac"},
+	{ErrNotMAC48, "not-mac48"},
+	{ErrInvalidMAC, "invalid-mac"},
+	{ErrNotEUI64, "not-eui64"},
+	{ErrInvalidPassword, "invalid-password"},
+	{ErrInvalidPacket, "invalid-packet"},
+	{ErrInterfaceNotFound, "interface-not-found"},
+	{ErrNoInterfaceAddress, "no-interface-address"},
+	{ErrLoopback, "loopback"},
+	{ErrNotInARPCache, "not-in-arp-cache"},
+	{ErrResolveFailed, "resolve-failed"},
+	{ErrDialFailed, "dial-failed"},
+	{ErrWriteTimeout, "write-timeout"},
+	{ErrShortWrite, "short-write"},
+	{ErrBannerMismatch, "banner-mismatch"},
+}
+
+// ErrorCode returns a stable identifier for the kind of err, such as
+// "invalid-mac" or "short-write", for scripts to act on without matching the
+// message, which may change. It's the Code of the first error in err's chain
+// with a Code method, and "" for an error from outside this package.
+func ErrorCode(err error) string {
+	var coder interface{ Code() string }
+	if errors.As(err, &coder) {
+		return coder.Code()
+	}
+	return sentinelCode(err)
+}
+
+// sentinelCode returns the code of the sentinel error err matches, if any.
+func sentinelCode(err error) string {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ""
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ShortWriteError reports that a write sent fewer bytes of a magic packet than
+// the packet is long, e.g. because the network truncated the datagram.
+type ShortWriteError struct {
+	Written  int
+	Expected int
+}
+
+func (e *ShortWriteError) Error() string {
+	return fmt.Sprintf("magic packet sent was %d bytes (expected %d bytes sent)", e.Written, e.Expected)
+}
+
+func (e *ShortWriteError) Is(target error) bool {
+	return target == ErrShortWrite
+}
+
+// Code returns "short-write", see ErrorCode.
+func (e *ShortWriteError) Code() string {
+	return "short-write"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// detailedError matches a sentinel error (and the errors the sentinel wraps),
+// while keeping its own message and underlying cause.
+type detailedError struct {
+	sentinel error
+	msg      string
+	cause    error
+}
+
+// newError returns an error matching sentinel, with a message built from
+// format and args, which unwraps to cause (which may be nil).
+func newError(sentinel, cause error, format string, args ...interface{}) error {
+	return &detailedError{
+		sentinel: sentinel,
+		msg:      fmt.Sprintf(format, args...),
+		cause:    cause,
+	}
+}
+
+func (e *detailedError) Error() string {
+	return e.msg
+}
+
+func (e *detailedError) Is(target error) bool {
+	return errors.Is(e.sentinel, target)
+}
+
+func (e *detailedError) Unwrap() error {
+	return e.cause
+}
+
+// Code returns the code of the sentinel error matched, see ErrorCode.
+func (e *detailedError) Code() string {
+	return sentinelCode(e.sentinel)
+}
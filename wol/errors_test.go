@@ -0,0 +1,54 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestErrorCode(t *testing.T) {
+	_, invalidMAC := MagicPacketNew("not-a-mac")
+	_, reservedMAC := MagicPacketNew("ff:ff:ff:ff:ff:ff")
+	_, _, noInterface := ipFromInterface("no-such-interface0")
+	_, noResolve := parseUDPAddr("relay.example.com", DefaultPort)
+
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{err: invalidMAC, want: "invalid-mac"},
+		{err: reservedMAC, want: "reserved-mac"},
+		{err: fmt.Errorf("waking nas: %w", invalidMAC), want: "invalid-mac"},
+		{err: noInterface, want: "interface-not-found"},
+		{err: &ShortWriteError{Written: 100, Expected: 102}, want: "short-write"},
+		{err: &NotUpError{Addr: "192.168.1.10:22", Err: context.DeadlineExceeded}, want: "not-up"},
+		{err: ErrWriteTimeout, want: "write-timeout"},
+		{err: noResolve, want: ""},
+		{err: errors.New("something else"), want: ""},
+		{err: nil, want: ""},
+	} {
+		if got := ErrorCode(tc.err); got != tc.want {
+			t.Errorf("ErrorCode(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestErrorCodeDial(t *testing.T) {
+	mp, err := MagicPacketNew("18:18:18:18:18:18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, _, err = Send(ctx, mp, WithBroadcast("relay.invalid"))
+	if got := ErrorCode(err); got != "resolve-failed" {
+		t.Errorf("ErrorCode of an unresolvable destination (%v) = %q, want resolve-failed", err, got)
+	}
+}
@@ -0,0 +1,46 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"net"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// MACFromEUI64 recovers the MAC-48 address a modified EUI-64 interface
+// identifier was derived from, by removing the 0xFFFE inserted in its middle
+// and flipping the universal/local bit back. eui is either the 8 byte
+// identifier, e.g. "02:1a:2b:ff:fe:3c:4d:5e", or an IPv6 address using it such
+// as "fe80::21a:2bff:fe3c:4d5e".
+func MACFromEUI64(eui string) (MACAddress, error) {
+	// 02:1a:2b:ff:fe:3c:4d:5e is also a valid IPv6 address, so the identifier
+	// forms are tried first.
+	var id []byte
+	if hwAddr, err := net.ParseMAC(eui); err == nil && len(hwAddr) == 8 {
+		id = hwAddr
+	} else if ip := net.ParseIP(eui); ip != nil && ip.To4() == nil {
+		id = ip[8:]
+	} else {
+		return MACAddress{}, newError(ErrNotEUI64, nil, "%s is not an EUI-64 identifier or IPv6 address", eui)
+	}
+
+	if id[3] != 0xFF || id[4] != 0xFE {
+		return MACAddress{}, newError(ErrNotEUI64, nil, "%s was not derived from a MAC address (expected 0xFFFE in its middle, found 0x%02X%02X)", eui, id[3], id[4])
+	}
+
+	return MACAddress{id[0] ^ 0x02, id[1], id[2], id[5], id[6], id[7]}, nil
+}
+
+// MagicPacketNewFromEUI64 returns a magic packet for the MAC address the
+// EUI-64 identifier eui was derived from, see MACFromEUI64.
+func MagicPacketNewFromEUI64(eui string) (*MagicPacket, error) {
+	macAddr, err := MACFromEUI64(eui)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWakeable(macAddr); err != nil {
+		return nil, err
+	}
+	return MagicPacketFromMAC(macAddr), nil
+}
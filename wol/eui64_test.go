@@ -0,0 +1,52 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestMACFromEUI64(t *testing.T) {
+	want := MACAddress{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}
+	for _, eui := range []string{
+		"02:1a:2b:ff:fe:3c:4d:5e",
+		"02-1A-2B-FF-FE-3C-4D-5E",
+		"021a.2bff.fe3c.4d5e",
+		"fe80::21a:2bff:fe3c:4d5e",
+		"2001:db8::21a:2bff:fe3c:4d5e",
+	} {
+		got, err := MACFromEUI64(eui)
+		if err != nil {
+			t.Errorf("MACFromEUI64(%q) failed: %s", eui, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("MACFromEUI64(%q) = %v, want %v", eui, got, want)
+		}
+	}
+
+	for _, eui := range []string{
+		"",
+		"00:1a:2b:3c:4d:5e",
+		"02:1a:2b:00:00:3c:4d:5e",
+		"fe80::1",
+		"192.168.1.1",
+	} {
+		if _, err := MACFromEUI64(eui); !errors.Is(err, ErrNotEUI64) {
+			t.Errorf("MACFromEUI64(%q) = %v, want ErrNotEUI64", eui, err)
+		}
+	}
+}
+
+func TestMagicPacketNewFromEUI64(t *testing.T) {
+	mp, err := MagicPacketNewFromEUI64("fe80::21a:2bff:fe3c:4d5e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mp.MAC().String(); got != "00:1a:2b:3c:4d:5e" {
+		t.Errorf("MagicPacketNewFromEUI64 woke %s, want 00:1a:2b:3c:4d:5e", got)
+	}
+}
@@ -0,0 +1,252 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ipFromInterface returns a `*net.UDPAddr` for the network interface selected
+// by iface, see WithInterface, along with the netmask of its subnet.
+func ipFromInterface(iface string) (*net.UDPAddr, net.IPMask, error) {
+	// An address or subnet picks the local address directly, which saves
+	// spelling out interface names such as "Ethernet 2" on Windows.
+	if ipNet := parseAddrSelector(iface); ipNet != nil {
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			return nil, nil, err
+		}
+		if addr := firstIPv4(addrs, ipNet); addr != nil {
+			return &net.UDPAddr{IP: addr.IP}, addr.Mask, nil
+		}
+		return nil, nil, newError(ErrNoInterfaceAddress, nil, "no interface has an address in %s", iface)
+	}
+
+	ief, err := interfaceByNameOrIndex(iface)
+	if err != nil {
+		return nil, nil, newError(ErrInterfaceNotFound, err, "interface %s: %s", iface, err)
+	}
+
+	if ief.Flags&net.FlagLoopback != 0 {
+		return nil, nil, newError(ErrLoopback, nil, "interface %s is a loopback interface, pick the one on the target's network", iface)
+	}
+
+	addrs, err := ief.Addrs()
+	if err != nil {
+		return nil, nil, fmt.Errorf("interface %s: %w", iface, err)
+	}
+
+	// Validate that one of the addrs is a valid network IP address.
+	if addr := firstIPv4(addrs, nil); addr != nil {
+		return &net.UDPAddr{IP: addr.IP}, addr.Mask, nil
+	}
+	return nil, nil, newError(ErrNoInterfaceAddress, nil, "no address associated with interface %s", iface)
+}
+
+// interfaceByNameOrIndex looks an interface up by its name, falling back to a
+// case insensitive match as on Windows, and then to its index.
+func interfaceByNameOrIndex(iface string) (*net.Interface, error) {
+	ief, err := net.InterfaceByName(iface)
+	if err == nil {
+		return ief, nil
+	}
+
+	iefs, listErr := net.Interfaces()
+	if listErr != nil {
+		return nil, err
+	}
+	for idx := range iefs {
+		if strings.EqualFold(iefs[idx].Name, iface) {
+			return &iefs[idx], nil
+		}
+	}
+	if index, convErr := strconv.Atoi(iface); convErr == nil {
+		return net.InterfaceByIndex(index)
+	}
+	return nil, err
+}
+
+// parseAddrSelector parses an interface selector given as an IPv4 address or
+// subnet, returning nil for anything else such as an interface name.
+func parseAddrSelector(iface string) *net.IPNet {
+	if ip := net.ParseIP(iface).To4(); ip != nil {
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}
+	}
+	if _, ipNet, err := net.ParseCIDR(iface); err == nil && ipNet.IP.To4() != nil {
+		return ipNet
+	}
+	return nil
+}
+
+// firstIPv4 returns the first non-loopback IPv4 address in addrs, which is also
+// in within when that isn't nil.
+func firstIPv4(addrs []net.Addr, within *net.IPNet) *net.IPNet {
+	for _, addr := range addrs {
+		switch ip := addr.(type) {
+		case *net.IPNet:
+			if !ip.IP.IsLoopback() && ip.IP.To4() != nil && (within == nil || within.Contains(ip.IP)) {
+				return ip
+			}
+		}
+	}
+	return nil
+}
+
+// BroadcastInterfaces returns the names of the network interfaces a magic
+// packet can be sent from with WithInterface: those which are up, aren't
+// loopback and have an IPv4 address.
+func BroadcastInterfaces() ([]string, error) {
+	return BroadcastInterfacesSkipping(nil)
+}
+
+// BroadcastInterfacesSkipping is BroadcastInterfaces, calling skipped (when
+// not nil) for each interface which is up but can't be sent from, such as one
+// with only IPv6 addresses or none at all, and why.
+func BroadcastInterfacesSkipping(skipped func(iface string, err error)) ([]string, error) {
+	iefs, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, ief := range iefs {
+		if ief.Flags&net.FlagUp == 0 || ief.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if _, _, err := ipFromInterface(ief.Name); err != nil {
+			if skipped != nil {
+				skipped(ief.Name, err)
+			}
+			continue
+		}
+		names = append(names, ief.Name)
+	}
+	if len(names) == 0 {
+		return nil, newError(ErrNoInterfaceAddress, nil, "no network interface is up with an IPv4 address")
+	}
+	return names, nil
+}
+
+// SubnetBroadcast is the directed broadcast address of the subnet a local
+// network interface is on.
+type SubnetBroadcast struct {
+	Interface string
+	Subnet    *net.IPNet
+	Broadcast net.IP
+}
+
+// SubnetBroadcasts returns the directed broadcast address of the IPv4 subnet of
+// every interface in BroadcastInterfaces, for waking a machine which is on one
+// of the local subnets without knowing which. Point-to-point (/31) and host
+// (/32) subnets have no broadcast address and are skipped.
+func SubnetBroadcasts() ([]SubnetBroadcast, error) {
+	names, err := BroadcastInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var subnets []SubnetBroadcast
+	for _, name := range names {
+		addr, mask, err := ipFromInterface(name)
+		if err != nil {
+			return nil, err
+		}
+		if bcast := DirectedBroadcast(addr.IP, mask); bcast != nil {
+			subnets = append(subnets, SubnetBroadcast{
+				Interface: name,
+				Subnet:    &net.IPNet{IP: addr.IP.Mask(mask), Mask: mask},
+				Broadcast: bcast,
+			})
+		}
+	}
+	if len(subnets) == 0 {
+		return nil, newError(ErrNoInterfaceAddress, nil, "no network interface is on an IPv4 subnet with a broadcast address")
+	}
+	return subnets, nil
+}
+
+// routeProbeAddr is an address off every local subnet (TEST-NET-2, RFC 5737),
+// whose route is the default route.
+const routeProbeAddr = "198.51.100.1:9"
+
+// DefaultRouteBroadcast returns the directed broadcast address of the IPv4
+// subnet of the interface the default route goes out of, the interface a
+// machine with a single network is most likely to be woken through. Finding
+// the route sends no packets.
+func DefaultRouteBroadcast() (SubnetBroadcast, error) {
+	conn, err := net.Dial("udp4", routeProbeAddr)
+	if err != nil {
+		return SubnetBroadcast{}, fmt.Errorf("finding the default route: %w", err)
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	iefs, err := net.Interfaces()
+	if err != nil {
+		return SubnetBroadcast{}, err
+	}
+	for _, ief := range iefs {
+		addrs, err := ief.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !ipNet.IP.Equal(local) {
+				continue
+			}
+			bcast := DirectedBroadcast(ipNet.IP, ipNet.Mask)
+			if bcast == nil {
+				return SubnetBroadcast{}, newError(ErrNoInterfaceAddress, nil, "the default route's subnet %s has no broadcast address", ipNet)
+			}
+			return SubnetBroadcast{
+				Interface: ief.Name,
+				Subnet:    &net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask},
+				Broadcast: bcast,
+			}, nil
+		}
+	}
+	return SubnetBroadcast{}, newError(ErrNoInterfaceAddress, nil, "no interface has the default route's address %s", local)
+}
+
+// isBroadcastAddr reports whether ip is the limited broadcast address,
+// 255.255.255.255, or the directed broadcast address of the subnet of a local
+// network interface.
+func isBroadcastAddr(ip net.IP) bool {
+	if ip.Equal(net.IPv4bcast) {
+		return true
+	}
+	if ip.To4() == nil {
+		return false
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ip.Equal(DirectedBroadcast(ipNet.IP, ipNet.Mask)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DirectedBroadcast returns the broadcast address of the IPv4 subnet ip/mask,
+// or nil if the subnet is too small to have one.
+func DirectedBroadcast(ip net.IP, mask net.IPMask) net.IP {
+	ip = ip.To4()
+	if ones, bits := mask.Size(); ip == nil || bits != 8*net.IPv4len || ones > 30 {
+		return nil
+	}
+
+	bcast := make(net.IP, net.IPv4len)
+	for idx := range ip {
+		bcast[idx] = ip[idx] | ^mask[idx]
+	}
+	return bcast
+}
@@ -0,0 +1,107 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"net"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestFirstIPv4(t *testing.T) {
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+		&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+		&net.IPNet{IP: net.ParseIP("192.168.1.20").To4(), Mask: net.CIDRMask(24, 32)},
+		&net.IPNet{IP: net.ParseIP("10.0.5.3").To4(), Mask: net.CIDRMask(24, 32)},
+	}
+
+	for _, tc := range []struct {
+		selector string
+		want     string
+	}{
+		{selector: "", want: "192.168.1.20/24"},
+		{selector: "10.0.5.3", want: "10.0.5.3/24"},
+		{selector: "10.0.0.0/16", want: "10.0.5.3/24"},
+		{selector: "192.168.1.0/24", want: "192.168.1.20/24"},
+		{selector: "172.16.0.0/12", want: "<nil>"},
+		{selector: "127.0.0.1", want: "<nil>"},
+	} {
+		var within *net.IPNet
+		if tc.selector != "" {
+			within = parseAddrSelector(tc.selector)
+			if within == nil {
+				t.Fatalf("parseAddrSelector(%q) = nil", tc.selector)
+			}
+		}
+		if got := firstIPv4(addrs, within).String(); got != tc.want {
+			t.Errorf("firstIPv4 within %q = %s, want %s", tc.selector, got, tc.want)
+		}
+	}
+
+	for _, name := range []string{"eth0", "Ethernet 2", "3", "fe80::1"} {
+		if within := parseAddrSelector(name); within != nil {
+			t.Errorf("parseAddrSelector(%q) = %s, want nil", name, within)
+		}
+	}
+}
+
+func TestDirectedBroadcast(t *testing.T) {
+	for _, tc := range []struct {
+		ip   string
+		ones int
+		want string
+	}{
+		{ip: "192.168.1.20", ones: 24, want: "192.168.1.255"},
+		{ip: "10.0.5.3", ones: 16, want: "10.0.255.255"},
+		{ip: "172.16.0.9", ones: 30, want: "172.16.0.11"},
+		{ip: "172.16.0.9", ones: 31, want: "<nil>"},
+		{ip: "172.16.0.9", ones: 32, want: "<nil>"},
+	} {
+		got := DirectedBroadcast(net.ParseIP(tc.ip), net.CIDRMask(tc.ones, 32)).String()
+		if got != tc.want {
+			t.Errorf("DirectedBroadcast(%s/%d) = %s, want %s", tc.ip, tc.ones, got, tc.want)
+		}
+	}
+}
+
+func TestIsBroadcastAddr(t *testing.T) {
+	for _, tc := range []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "255.255.255.255", want: true},
+		{ip: "127.0.0.1", want: false},
+		{ip: "198.51.100.1", want: false},
+		{ip: "239.255.0.9", want: false},
+		{ip: "ff02::1", want: false},
+	} {
+		if got := isBroadcastAddr(net.ParseIP(tc.ip)); got != tc.want {
+			t.Errorf("isBroadcastAddr(%s) = %t, want %t", tc.ip, got, tc.want)
+		}
+	}
+
+	subnets, err := SubnetBroadcasts()
+	if err != nil {
+		t.Skipf("no local subnet to test with: %s", err)
+	}
+	for _, subnet := range subnets {
+		if !isBroadcastAddr(subnet.Broadcast) {
+			t.Errorf("isBroadcastAddr(%s) = false for the broadcast of %s on %s", subnet.Broadcast, subnet.Subnet, subnet.Interface)
+		}
+	}
+}
+
+func TestDefaultRouteBroadcast(t *testing.T) {
+	subnet, err := DefaultRouteBroadcast()
+	if err != nil {
+		t.Skipf("no default route to test with: %s", err)
+	}
+	if !subnet.Subnet.Contains(subnet.Broadcast) {
+		t.Errorf("DefaultRouteBroadcast() = %s, which isn't on its subnet %s", subnet.Broadcast, subnet.Subnet)
+	}
+	if want := DirectedBroadcast(subnet.Subnet.IP, subnet.Subnet.Mask); !subnet.Broadcast.Equal(want) {
+		t.Errorf("DefaultRouteBroadcast() = %s, want %s for %s", subnet.Broadcast, want, subnet.Subnet)
+	}
+}
@@ -0,0 +1,391 @@
+// Package wol builds, parses and sends Wake-on-LAN magic packets using only
+// the Go standard library.
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// DefaultRepetitions is the number of times the standard magic packet repeats
+// the destination MAC address.
+const DefaultRepetitions = 16
+
+////////////////////////////////////////////////////////////////////////////////
+
+var (
+	delims = ":-"
+	reMAC  = regexp.MustCompile(`^([0-9a-fA-F]{2}[` + delims + `]){5}([0-9a-fA-F]{2})$`)
+
+	// Vendor tools often print MAC addresses as 12 bare hex digits.
+	reBareMAC = regexp.MustCompile(`^[0-9a-fA-F]{12}$`)
+
+	// SecureOn passwords are either 4 or 6 hex bytes, using the same
+	// delimiters as the MAC address.
+	rePassword = regexp.MustCompile(`^([0-9a-fA-F]{2}[` + delims + `]){3}(([0-9a-fA-F]{2}[` + delims + `]){2})?([0-9a-fA-F]{2})$`)
+)
+
+// asciiPasswordPrefix marks a SecureOn password given as its 6 characters
+// rather than in hex.
+const asciiPasswordPrefix = "ascii:"
+
+////////////////////////////////////////////////////////////////////////////////
+
+// MACAddress represents a 6 byte network mac address.
+type MACAddress [6]byte
+
+// String returns the mac address in its canonical lowercase, colon delimited
+// form, e.g. "18:18:18:18:18:18".
+func (m MACAddress) String() string {
+	return net.HardwareAddr(m[:]).String()
+}
+
+// MarshalText implements encoding.TextMarshaler, so that a MACAddress is
+// written to JSON and other text formats in its canonical String form.
+func (m MACAddress) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting the same forms
+// as MagicPacketNew, so that config structs can hold a validated MACAddress.
+func (m *MACAddress) UnmarshalText(text []byte) error {
+	macAddr, err := parseMAC(string(text))
+	if err != nil {
+		return err
+	}
+	*m = macAddr
+	return nil
+}
+
+// MagicPacket is constituted of 6 bytes of 0xFF followed by 16-groups of the
+// destination MAC address, optionally followed by a 4 or 6 byte SecureOn
+// password. SetRepetitions changes the number of groups for devices which
+// expect a non-standard count, and SetTag appends non-standard trailing bytes.
+type MagicPacket struct {
+	header   [6]byte
+	payload  []MACAddress
+	password []byte
+	tag      []byte
+}
+
+// MagicPacketNew returns a magic packet based on a mac address string. The
+// all-zero and broadcast MAC addresses are rejected with ErrReservedMAC, they
+// are a copy-paste mistake rather than a machine to wake.
+func MagicPacketNew(mac string) (*MagicPacket, error) {
+	macAddr, err := parseMAC(mac)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWakeable(macAddr); err != nil {
+		return nil, err
+	}
+	return MagicPacketFromMAC(macAddr), nil
+}
+
+// checkWakeable rejects the MAC addresses no network card answers to.
+func checkWakeable(macAddr MACAddress) error {
+	switch macAddr {
+	case MACAddress{}, MACAddress{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}:
+		return newError(ErrReservedMAC, nil, "%s is a reserved MAC address, not one of a network card", macAddr)
+	}
+	return nil
+}
+
+// MagicPacketFromMAC returns a magic packet for a mac address which is already
+// in binary form, skipping the string parsing and checks of MagicPacketNew.
+func MagicPacketFromMAC(macAddr MACAddress) *MagicPacket {
+	var packet MagicPacket
+
+	// Setup the header which is 6 repetitions of 0xFF.
+	for idx := range packet.header {
+		packet.header[idx] = 0xFF
+	}
+
+	// Setup the payload which is 16 repetitions of the MAC addr.
+	packet.payload = repeatMAC(macAddr, DefaultRepetitions)
+
+	return &packet
+}
+
+// SetRepetitions changes the number of times the packet repeats the MAC
+// address from the standard 16, which a few obscure devices require.
+func (mp *MagicPacket) SetRepetitions(n int) error {
+	if n < 1 {
+		return fmt.Errorf("magic packet needs at least 1 MAC repetition, not %d", n)
+	}
+	mp.payload = repeatMAC(mp.MAC(), n)
+	return nil
+}
+
+// Repetitions returns the number of times the packet repeats the MAC address.
+func (mp *MagicPacket) Repetitions() int {
+	return len(mp.payload)
+}
+
+// SetTag appends tag to the packet after the SecureOn password, if any, e.g. a
+// timestamp for diagnostic receivers to correlate repeated packets by. Such a
+// packet isn't a standard magic packet, and network cards may ignore it. An
+// empty tag removes it again.
+func (mp *MagicPacket) SetTag(tag []byte) {
+	if len(tag) == 0 {
+		mp.tag = nil
+		return
+	}
+	mp.tag = append([]byte(nil), tag...)
+}
+
+// Tag returns a copy of the bytes appended by SetTag, or nil if there are
+// none.
+func (mp *MagicPacket) Tag() []byte {
+	if mp.tag == nil {
+		return nil
+	}
+	return append([]byte(nil), mp.tag...)
+}
+
+// repeatMAC returns a payload of n repetitions of macAddr.
+func repeatMAC(macAddr MACAddress, n int) []MACAddress {
+	payload := make([]MACAddress, n)
+	for idx := range payload {
+		payload[idx] = macAddr
+	}
+	return payload
+}
+
+// MagicPacketFromHardwareAddr returns a magic packet for hwAddr, e.g. taken from
+// a net.Interface, which must be a 6 byte MAC-48 address.
+func MagicPacketFromHardwareAddr(hwAddr net.HardwareAddr) (*MagicPacket, error) {
+	macAddr, err := macFromHardwareAddr(hwAddr)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWakeable(macAddr); err != nil {
+		return nil, err
+	}
+	return MagicPacketFromMAC(macAddr), nil
+}
+
+// NormalizeMAC parses mac in any of the accepted forms, so that MAC addresses
+// can be compared regardless of their delimiters or case.
+func NormalizeMAC(mac string) (MACAddress, error) {
+	return parseMAC(mac)
+}
+
+// IsValidMAC reports whether mac is a MAC address MagicPacketNew accepts, for
+// validating input up front without building a packet.
+func IsValidMAC(mac string) bool {
+	macAddr, err := parseMAC(mac)
+	return err == nil && checkWakeable(macAddr) == nil
+}
+
+// parseMAC is the one place mac address strings are validated. Only the
+// xx:xx:xx:xx:xx:xx, xx-xx-xx-xx-xx-xx and xxxxxxxxxxxx forms are accepted,
+// anything else is rejected with the same error.
+func parseMAC(mac string) (MACAddress, error) {
+	var macAddr MACAddress
+	errNotMAC := func(cause error) error {
+		return newError(ErrInvalidMAC, cause, "%s is not a IEEE 802 MAC-48 address (expected xx:xx:xx:xx:xx:xx, xx-xx-xx-xx-xx-xx or xxxxxxxxxxxx)", mac)
+	}
+
+	if reBareMAC.MatchString(mac) {
+		if _, err := hex.Decode(macAddr[:], []byte(mac)); err != nil {
+			return macAddr, errNotMAC(err)
+		}
+		return macAddr, nil
+	}
+
+	// We only support 6 byte MAC addresses, so that every payload group has
+	// the same fixed size however many repetitions there are.
+	if !reMAC.MatchString(mac) {
+		return macAddr, errNotMAC(nil)
+	}
+
+	// net.ParseMAC additionally rejects mixed delimiters such as
+	// 18:18-18:18-18:18.
+	hwAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return macAddr, errNotMAC(err)
+	}
+	return macFromHardwareAddr(hwAddr)
+}
+
+// macFromHardwareAddr copies a 6 byte HardwareAddr into a MACAddress. The
+// 8 byte EUI-64 and 20 byte InfiniBand addresses net.ParseMAC also returns are
+// rejected with ErrNotMAC48.
+func macFromHardwareAddr(hwAddr net.HardwareAddr) (MACAddress, error) {
+	var macAddr MACAddress
+	if len(hwAddr) != len(macAddr) {
+		return macAddr, newError(ErrNotMAC48, nil, "%s is a %d byte hardware address, not a 6 byte IEEE 802 MAC-48 address", hwAddr, len(hwAddr))
+	}
+
+	// Copy bytes from the returned HardwareAddr -> a fixed size MACAddress.
+	copy(macAddr[:], hwAddr)
+	return macAddr, nil
+}
+
+// MagicPacketNewWithPassword returns a magic packet based on a mac address
+// string and a SecureOn password string such as "01:02:03:04:05:06", or
+// "ascii:secret" for the 6 characters some router UIs show instead.
+func MagicPacketNewWithPassword(mac, password string) (*MagicPacket, error) {
+	packet, err := MagicPacketNew(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	pass, err := parsePassword(password)
+	if err != nil {
+		return nil, err
+	}
+	packet.password = pass
+
+	return packet, nil
+}
+
+// parsePassword converts a delimited hex string, or 6 ASCII characters after
+// an "ascii:" prefix, into a 4 or 6 byte SecureOn password.
+func parsePassword(password string) ([]byte, error) {
+	if strings.HasPrefix(password, asciiPasswordPrefix) {
+		text := strings.TrimPrefix(password, asciiPasswordPrefix)
+		if len(text) != 6 || strings.IndexFunc(text, func(r rune) bool { return r > unicode.MaxASCII }) >= 0 {
+			return nil, newError(ErrInvalidPassword, nil, "%s is not a 6 character ASCII SecureOn password", password)
+		}
+		return []byte(text), nil
+	}
+	if !rePassword.MatchString(password) {
+		return nil, newError(ErrInvalidPassword, nil, "%s is not a 4 or 6 byte SecureOn password", password)
+	}
+
+	// The regexp guarantees the delimiters sit between each hex pair, so
+	// stripping them leaves an even number of hex digits.
+	digits := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(delims, r) {
+			return -1
+		}
+		return r
+	}, password)
+	return hex.DecodeString(digits)
+}
+
+// Marshal serializes the magic packet structure into a 102 byte slice, or a
+// 106 / 108 byte slice when a SecureOn password is set. Each repetition other
+// than the standard 16 adds or removes 6 bytes, and a tag adds its length.
+func (mp *MagicPacket) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := mp.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Build returns the bytes of the standard magic packet waking mac, for sending
+// it by other means than this package. Its length is that of Marshal: a
+// packet built with a password, a tag or other repetitions is longer or
+// shorter, see Size.
+func Build(mac string) ([]byte, error) {
+	mp, err := MagicPacketNew(mac)
+	if err != nil {
+		return nil, err
+	}
+	return mp.Marshal()
+}
+
+// Size returns the length of the serialized magic packet in bytes: 102 for a
+// standard packet, plus the SecureOn password and tag if any, and adjusted for
+// SetRepetitions.
+func (mp *MagicPacket) Size() int {
+	return len(mp.header) + len(mp.payload)*len(MACAddress{}) + len(mp.password) + len(mp.tag)
+}
+
+// WriteTo writes the serialized magic packet to w, implementing io.WriterTo.
+// The packet is written with a single Write, so that it makes up exactly one
+// datagram when w is a UDP connection.
+func (mp *MagicPacket) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.BigEndian, mp.header); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(&buf, binary.BigEndian, mp.payload); err != nil {
+		return 0, err
+	}
+	buf.Write(mp.password)
+	buf.Write(mp.tag)
+
+	return buf.WriteTo(w)
+}
+
+// String returns the serialized magic packet as a hex string, which is handy
+// for logging the exact bytes that are sent.
+func (mp *MagicPacket) String() string {
+	bs, err := mp.Marshal()
+	if err != nil {
+		return fmt.Sprintf("<invalid magic packet: %s>", err)
+	}
+	return hex.EncodeToString(bs)
+}
+
+// MagicPacketUnmarshal parses a raw 102 byte magic packet, or a 106 / 108 byte
+// packet carrying a SecureOn password. Only the standard 16 repetitions are
+// accepted, as a 6 byte password can't be told apart from an extra one.
+func MagicPacketUnmarshal(data []byte) (*MagicPacket, error) {
+	packet := MagicPacket{payload: make([]MACAddress, DefaultRepetitions)}
+
+	base := len(packet.header) + len(packet.payload)*len(MACAddress{})
+	switch len(data) - base {
+	case 0, 4, 6:
+	default:
+		return nil, newError(ErrInvalidPacket, nil, "magic packet is %d bytes (expected %d, %d or %d bytes)", len(data), base, base+4, base+6)
+	}
+
+	// The header must be 6 repetitions of 0xFF.
+	for idx := range packet.header {
+		if data[idx] != 0xFF {
+			return nil, newError(ErrInvalidPacket, nil, "magic packet header byte %d is 0x%02X (expected 0xFF)", idx, data[idx])
+		}
+		packet.header[idx] = data[idx]
+	}
+
+	// Every payload group must repeat the first MAC address.
+	offset := len(packet.header)
+	for idx := range packet.payload {
+		copy(packet.payload[idx][:], data[offset:])
+		if packet.payload[idx] != packet.payload[0] {
+			return nil, newError(ErrInvalidPacket, nil, "magic packet MAC group %d does not match the first MAC group", idx)
+		}
+		offset += len(MACAddress{})
+	}
+
+	if offset < len(data) {
+		packet.password = append([]byte(nil), data[offset:]...)
+	}
+
+	return &packet, nil
+}
+
+// MAC returns the destination MAC address the packet wakes, or the zero
+// MACAddress for a zero value MagicPacket.
+func (mp *MagicPacket) MAC() MACAddress {
+	if len(mp.payload) == 0 {
+		return MACAddress{}
+	}
+	return mp.payload[0]
+}
+
+// Password returns a copy of the SecureOn password carried by the packet, or
+// nil if there is none.
+func (mp *MagicPacket) Password() []byte {
+	if mp.password == nil {
+		return nil
+	}
+	return append([]byte(nil), mp.password...)
+}
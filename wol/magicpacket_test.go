@@ -0,0 +1,447 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestMagicPacketNew(t *testing.T) {
+	for _, tc := range []struct {
+		mac     string
+		want    MACAddress
+		wantErr bool
+	}{
+		{mac: "18:18:18:18:18:18", want: MACAddress{0x18, 0x18, 0x18, 0x18, 0x18, 0x18}},
+		{mac: "18-18-18-18-18-18", want: MACAddress{0x18, 0x18, 0x18, 0x18, 0x18, 0x18}},
+		{mac: "00:1a:2B:3c:4D:5e", want: MACAddress{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}},
+		{mac: "00-1A-2B-3C-4D-5E", want: MACAddress{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}},
+		{mac: "001a2b3c4d5e", want: MACAddress{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}},
+		{mac: "", wantErr: true},
+		{mac: "18:18:18:18:18", wantErr: true},
+		{mac: "18:18:18:18:18:18:18:18", wantErr: true},
+		{mac: "18:18-18:18-18:18", wantErr: true},
+		{mac: "18.18.18.18.18.18", wantErr: true},
+		{mac: "1818.1818.1818", wantErr: true},
+		{mac: "18:18:18:18:18:1g", wantErr: true},
+		{mac: "1818181818", wantErr: true},
+		{mac: " 18:18:18:18:18:18", wantErr: true},
+		{mac: "00:00:00:00:00:00", wantErr: true},
+		{mac: "FF-FF-FF-FF-FF-FF", wantErr: true},
+		{mac: "ffffffffffff", wantErr: true},
+	} {
+		if got := IsValidMAC(tc.mac); got == tc.wantErr {
+			t.Errorf("IsValidMAC(%q) = %t, want %t", tc.mac, got, !tc.wantErr)
+		}
+
+		mp, err := MagicPacketNew(tc.mac)
+		if tc.wantErr {
+			if !errors.Is(err, ErrInvalidMAC) {
+				t.Errorf("MagicPacketNew(%q) = %v, want ErrInvalidMAC", tc.mac, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("MagicPacketNew(%q) failed: %s", tc.mac, err)
+			continue
+		}
+
+		for idx, b := range mp.header {
+			if b != 0xFF {
+				t.Errorf("MagicPacketNew(%q) header[%d] = 0x%02X, want 0xFF", tc.mac, idx, b)
+			}
+		}
+		for idx, macAddr := range mp.payload {
+			if macAddr != tc.want {
+				t.Errorf("MagicPacketNew(%q) payload[%d] = %v, want %v", tc.mac, idx, macAddr, tc.want)
+			}
+		}
+	}
+}
+
+func TestMagicPacketFromMAC(t *testing.T) {
+	want, err := MagicPacketNew("00:1a:2b:3c:4d:5e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	macAddr := MACAddress{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}
+	if got := MagicPacketFromMAC(macAddr); got.String() != want.String() {
+		t.Errorf("MagicPacketFromMAC(%v) = %s, want %s", macAddr, got, want)
+	}
+
+	got, err := MagicPacketFromHardwareAddr(net.HardwareAddr(macAddr[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("MagicPacketFromHardwareAddr(%v) = %s, want %s", macAddr, got, want)
+	}
+	if _, err := MagicPacketFromHardwareAddr(net.HardwareAddr{1, 2, 3}); !errors.Is(err, ErrNotMAC48) {
+		t.Errorf("MagicPacketFromHardwareAddr of 3 bytes = %v, want ErrNotMAC48", err)
+	}
+	if _, err := MagicPacketFromHardwareAddr(make(net.HardwareAddr, 6)); !errors.Is(err, ErrReservedMAC) {
+		t.Errorf("MagicPacketFromHardwareAddr of 00:00:00:00:00:00 = %v, want ErrReservedMAC", err)
+	}
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	want, err := NormalizeMAC("18:18:18:18:18:1a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mac := range []string{"18-18-18-18-18-1A", "18:18:18:18:18:1A", "18181818181a"} {
+		got, err := NormalizeMAC(mac)
+		if err != nil {
+			t.Errorf("NormalizeMAC(%q) failed: %s", mac, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("NormalizeMAC(%q) = %v, want %v", mac, got, want)
+		}
+	}
+
+	for _, mac := range []string{"", "18:18:18:18:18", "18.18.18.18.18.18", "nas"} {
+		if _, err := NormalizeMAC(mac); err == nil {
+			t.Errorf("NormalizeMAC(%q) succeeded, want error", mac)
+		}
+	}
+}
+
+func TestMACFromHardwareAddr(t *testing.T) {
+	want := MACAddress{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}
+	if got, err := macFromHardwareAddr(net.HardwareAddr(want[:])); err != nil || got != want {
+		t.Errorf("macFromHardwareAddr(%s) = %v, %v, want %v", net.HardwareAddr(want[:]), got, err, want)
+	}
+
+	for _, hwAddr := range []string{"00:1a:2b:ff:fe:3c:4d:5e", "00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01"} {
+		hw, err := net.ParseMAC(hwAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = macFromHardwareAddr(hw)
+		if !errors.Is(err, ErrNotMAC48) || !errors.Is(err, ErrInvalidMAC) {
+			t.Errorf("macFromHardwareAddr(%s) = %v, want ErrNotMAC48", hwAddr, err)
+		}
+	}
+}
+
+func TestMACAddressString(t *testing.T) {
+	for _, mac := range []string{"00:1A:2B:3C:4D:5E", "00-1a-2b-3c-4d-5e", "001A2b3C4d5E"} {
+		mp, err := MagicPacketNew(mac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := mp.MAC().String(), "00:1a:2b:3c:4d:5e"; got != want {
+			t.Errorf("MagicPacketNew(%q).MAC().String() = %q, want %q", mac, got, want)
+		}
+	}
+}
+
+func TestMACAddressText(t *testing.T) {
+	type config struct {
+		MAC MACAddress `json:"mac"`
+	}
+
+	for _, mac := range []string{"00:1a:2b:3c:4d:5e", "00-1A-2B-3C-4D-5E", "001a2b3c4d5e"} {
+		var c config
+		if err := json.Unmarshal([]byte(`{"mac": "`+mac+`"}`), &c); err != nil {
+			t.Errorf("unmarshaling %q failed: %s", mac, err)
+			continue
+		}
+		if want := (MACAddress{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}); c.MAC != want {
+			t.Errorf("unmarshaling %q = %v, want %v", mac, c.MAC, want)
+		}
+
+		bs, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(bs), `{"mac":"00:1a:2b:3c:4d:5e"}`; got != want {
+			t.Errorf("round-tripping %q = %s, want %s", mac, got, want)
+		}
+	}
+
+	var c config
+	if err := json.Unmarshal([]byte(`{"mac": "18:18:18:18:18"}`), &c); !errors.Is(err, ErrInvalidMAC) {
+		t.Errorf("unmarshaling a short MAC address = %v, want ErrInvalidMAC", err)
+	}
+}
+
+func TestMagicPacketMarshal(t *testing.T) {
+	mac := MACAddress{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}
+	for _, tc := range []struct {
+		mac      string
+		password string
+		wantLen  int
+	}{
+		{mac: "00:1a:2b:3c:4d:5e", wantLen: 102},
+		{mac: "00-1a-2b-3c-4d-5e", wantLen: 102},
+		{mac: "00:1a:2b:3c:4d:5e", password: "01:02:03:04", wantLen: 106},
+		{mac: "00:1a:2b:3c:4d:5e", password: "01-02-03-04-05-06", wantLen: 108},
+		{mac: "00:1a:2b:3c:4d:5e", password: "ascii:secret", wantLen: 108},
+	} {
+		var mp *MagicPacket
+		var err error
+		if tc.password == "" {
+			mp, err = MagicPacketNew(tc.mac)
+		} else {
+			mp, err = MagicPacketNewWithPassword(tc.mac, tc.password)
+		}
+		if err != nil {
+			t.Fatalf("building packet for %q / %q failed: %s", tc.mac, tc.password, err)
+		}
+
+		bs, err := mp.Marshal()
+		if err != nil {
+			t.Fatalf("Marshal failed: %s", err)
+		}
+		if len(bs) != tc.wantLen {
+			t.Fatalf("Marshal returned %d bytes, want %d", len(bs), tc.wantLen)
+		}
+		if mp.Size() != len(bs) {
+			t.Errorf("Size() = %d, want %d", mp.Size(), len(bs))
+		}
+
+		// 6 bytes of 0xFF, then 16 repetitions of the MAC, then the password.
+		if !bytes.Equal(bs[:6], bytes.Repeat([]byte{0xFF}, 6)) {
+			t.Errorf("header = % X, want six 0xFF bytes", bs[:6])
+		}
+		if !bytes.Equal(bs[6:102], bytes.Repeat(mac[:], 16)) {
+			t.Errorf("payload = % X, want 16 repetitions of % X", bs[6:102], mac[:])
+		}
+		if tc.password != "" && !bytes.Equal(bs[102:], mp.Password()) {
+			t.Errorf("trailer = % X, want password % X", bs[102:], mp.Password())
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	mac := MACAddress{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}
+	bs, err := Build("00-1a-2b-3c-4d-5e")
+	if err != nil {
+		t.Fatalf("Build failed: %s", err)
+	}
+	want := append(bytes.Repeat([]byte{0xFF}, 6), bytes.Repeat(mac[:], 16)...)
+	if !bytes.Equal(bs, want) {
+		t.Errorf("Build = % X, want % X", bs, want)
+	}
+
+	if _, err := Build("not-a-mac"); !errors.Is(err, ErrInvalidMAC) {
+		t.Errorf("Build with an invalid MAC = %v, want ErrInvalidMAC", err)
+	}
+}
+
+func TestMagicPacketWriteTo(t *testing.T) {
+	mp, err := MagicPacketNewWithPassword("00:1a:2b:3c:4d:5e", "01:02:03:04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := mp.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var w io.WriterTo = mp
+	var buf bytes.Buffer
+	n, err := w.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("WriteTo wrote %d bytes % X, want %d bytes % X", n, buf.Bytes(), len(want), want)
+	}
+}
+
+func TestMagicPacketSetRepetitions(t *testing.T) {
+	mac := MACAddress{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}
+	mp, err := MagicPacketNewWithPassword(mac.String(), "01:02:03:04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mp.Repetitions(); got != DefaultRepetitions {
+		t.Errorf("Repetitions() = %d, want %d", got, DefaultRepetitions)
+	}
+
+	for _, n := range []int{1, 8, 20} {
+		if err := mp.SetRepetitions(n); err != nil {
+			t.Fatalf("SetRepetitions(%d) failed: %s", n, err)
+		}
+		bs, err := mp.Marshal()
+		if err != nil {
+			t.Fatalf("Marshal failed: %s", err)
+		}
+		if want := 6 + 6*n + 4; len(bs) != want || mp.Size() != want {
+			t.Fatalf("SetRepetitions(%d): Marshal returned %d bytes and Size() %d, want %d", n, len(bs), mp.Size(), want)
+		}
+		if !bytes.Equal(bs[6:6+6*n], bytes.Repeat(mac[:], n)) {
+			t.Errorf("SetRepetitions(%d): payload = % X, want %d repetitions of % X", n, bs[6:6+6*n], n, mac[:])
+		}
+		if mp.MAC() != mac {
+			t.Errorf("SetRepetitions(%d): MAC() = %v, want %v", n, mp.MAC(), mac)
+		}
+	}
+
+	for _, n := range []int{0, -1} {
+		if err := mp.SetRepetitions(n); err == nil {
+			t.Errorf("SetRepetitions(%d) succeeded, want an error", n)
+		}
+	}
+}
+
+func TestMagicPacketSetTag(t *testing.T) {
+	mp, err := MagicPacketNewWithPassword("00:1a:2b:3c:4d:5e", "01:02:03:04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := mp.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := []byte("1760443200")
+	mp.SetTag(tag)
+	tag[0] = 'x' // SetTag keeps its own copy
+	bs, err := mp.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(plain[:len(plain):len(plain)], "1760443200"...); !bytes.Equal(bs, want) {
+		t.Errorf("tagged packet = % X, want % X", bs, want)
+	}
+	if got := mp.Tag(); string(got) != "1760443200" {
+		t.Errorf("Tag() = %q, want %q", got, "1760443200")
+	}
+
+	mp.SetTag(nil)
+	if bs, _ := mp.Marshal(); !bytes.Equal(bs, plain) || mp.Tag() != nil {
+		t.Errorf("packet after SetTag(nil) = % X (tag %q), want % X", bs, mp.Tag(), plain)
+	}
+}
+
+func TestMagicPacketNewWithASCIIPassword(t *testing.T) {
+	mp, err := MagicPacketNewWithPassword("00:1a:2b:3c:4d:5e", "ascii:secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mp.Password(); string(got) != "secret" {
+		t.Errorf("Password() = %q, want %q", got, "secret")
+	}
+}
+
+func TestMagicPacketNewWithPasswordInvalid(t *testing.T) {
+	for _, password := range []string{
+		"",
+		"01:02:03",
+		"01:02:03:04:05",
+		"01:02:03:04:05:06:07",
+		"0102030405",
+		"01:02:03:0g",
+		"ascii:",
+		"ascii:short",
+		"ascii:toolong",
+		"ascii:sécre",
+	} {
+		if _, err := MagicPacketNewWithPassword("18:18:18:18:18:18", password); !errors.Is(err, ErrInvalidPassword) {
+			t.Errorf("MagicPacketNewWithPassword(%q) = %v, want ErrInvalidPassword", password, err)
+		}
+	}
+}
+
+func TestMagicPacketZeroValue(t *testing.T) {
+	var mp MagicPacket
+	if got := mp.MAC(); got != (MACAddress{}) {
+		t.Errorf("MAC() = %s, want the zero MACAddress", got)
+	}
+	if got := mp.Repetitions(); got != 0 {
+		t.Errorf("Repetitions() = %d, want 0", got)
+	}
+	_ = mp.String()
+	if err := mp.SetRepetitions(2); err != nil {
+		t.Fatal(err)
+	}
+	if got := mp.MAC(); got != (MACAddress{}) {
+		t.Errorf("MAC() after SetRepetitions = %s, want the zero MACAddress", got)
+	}
+	if got := mp.Repetitions(); got != 2 {
+		t.Errorf("Repetitions() after SetRepetitions = %d, want 2", got)
+	}
+}
+
+func TestMagicPacketUnmarshal(t *testing.T) {
+	mp, err := MagicPacketNewWithPassword("00:1a:2b:3c:4d:5e", "01:02:03:04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := mp.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := MagicPacketUnmarshal(bs)
+	if err != nil {
+		t.Fatalf("MagicPacketUnmarshal failed: %s", err)
+	}
+	if got.MAC() != mp.MAC() || !bytes.Equal(got.Password(), mp.Password()) {
+		t.Errorf("MagicPacketUnmarshal = %v / % X, want %v / % X", got.MAC(), got.Password(), mp.MAC(), mp.Password())
+	}
+
+	short := bs[:101]
+	badHeader := append([]byte(nil), bs...)
+	badHeader[0] = 0x00
+	badGroup := append([]byte(nil), bs...)
+	badGroup[50] ^= 0xFF
+	for name, data := range map[string][]byte{
+		"short":      short,
+		"bad header": badHeader,
+		"bad group":  badGroup,
+	} {
+		if _, err := MagicPacketUnmarshal(data); !errors.Is(err, ErrInvalidPacket) {
+			t.Errorf("MagicPacketUnmarshal(%s) = %v, want ErrInvalidPacket", name, err)
+		}
+	}
+}
+
+func FuzzMagicPacketUnmarshal(f *testing.F) {
+	for _, password := range []string{"", "01:02:03:04", "01:02:03:04:05:06"} {
+		mp, err := MagicPacketNew("00:1a:2b:3c:4d:5e")
+		if password != "" {
+			mp, err = MagicPacketNewWithPassword("00:1a:2b:3c:4d:5e", password)
+		}
+		if err != nil {
+			f.Fatal(err)
+		}
+		bs, err := mp.Marshal()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(bs)
+	}
+	f.Add([]byte{})
+	f.Add(bytes.Repeat([]byte{0xFF}, 102))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		mp, err := MagicPacketUnmarshal(data)
+		if err != nil {
+			if mp != nil || !errors.Is(err, ErrInvalidPacket) {
+				t.Fatalf("MagicPacketUnmarshal(% X) = %v, %v, want nil and ErrInvalidPacket", data, mp, err)
+			}
+			return
+		}
+
+		// A valid packet serializes back to exactly the bytes it was parsed from.
+		bs, err := mp.Marshal()
+		if err != nil {
+			t.Fatalf("Marshal of parsed packet failed: %s", err)
+		}
+		if !bytes.Equal(bs, data) {
+			t.Fatalf("MagicPacketUnmarshal(% X) round trips to % X", data, bs)
+		}
+	})
+}
@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+// setBroadcast is a no-op where there are no socket options to set, e.g. on
+// Plan 9 or js/wasm.
+func setBroadcast(fd uintptr) error {
+	return nil
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestDialSetsBroadcast(t *testing.T) {
+	o := newOptions([]Option{WithBroadcast("127.0.0.1")})
+	conn, err := dial(context.Background(), &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	raw, err := conn.(*net.UDPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value int
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		value, sockErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if sockErr != nil {
+		t.Fatal(sockErr)
+	}
+	if value == 0 {
+		t.Error("SO_BROADCAST is not set on the dialed socket")
+	}
+}
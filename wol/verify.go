@@ -0,0 +1,194 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	// DefaultWaitPort is the TCP port polled to tell whether a woken host is
+	// up, SSH being the service most likely to be listening.
+	DefaultWaitPort = 22
+
+	// DefaultRetryDelay is how long WakeUntilUp waits for the host after its
+	// first magic packet, doubling with each attempt after that.
+	DefaultRetryDelay = 10 * time.Second
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// NotUpError reports that a host sent a magic packet didn't accept connections
+// in time.
+type NotUpError struct {
+	Addr     string
+	Attempts int // magic packets sent by WakeUntilUp, 0 for WaitForHost
+	Err      error
+}
+
+func (e *NotUpError) Error() string {
+	if e.Attempts > 0 {
+		return fmt.Sprintf("%s still not up after %d wake attempts", e.Addr, e.Attempts)
+	}
+	return fmt.Sprintf("timed out waiting for %s to come up: %s", e.Addr, e.Err)
+}
+
+func (e *NotUpError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns "banner-mismatch" when the host kept sending the wrong banner,
+// and "not-up" otherwise, see ErrorCode.
+func (e *NotUpError) Code() string {
+	if errors.Is(e.Err, ErrBannerMismatch) {
+		return "banner-mismatch"
+	}
+	return "not-up"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// WithWaitPort sets the TCP port WakeUntilUp polls, which defaults to
+// DefaultWaitPort.
+func WithWaitPort(port int) Option {
+	return func(o *options) {
+		o.waitPort = port
+	}
+}
+
+// WithRetryDelay sets how long WakeUntilUp waits for the host to come up after
+// the first magic packet. Every further attempt waits twice as long as the one
+// before.
+func WithRetryDelay(delay time.Duration) Option {
+	return func(o *options) {
+		o.retryDelay = delay
+	}
+}
+
+// WithBanner makes WakeUntilUp only confirm the host up once the first line it
+// sends on the wait port matches banner, e.g. the version string of its SSH
+// server, to tell that the right machine woke.
+func WithBanner(banner *regexp.Regexp) Option {
+	return func(o *options) {
+		o.banner = banner
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// WaitForHost polls addr ("host:port") with a TCP connection attempt every
+// interval, until one succeeds or ctx is done. A host that has just been sent
+// a magic packet is only confirmed to be up once WaitForHost returns nil, a
+// *NotUpError is returned otherwise.
+func WaitForHost(ctx context.Context, addr string, interval time.Duration) error {
+	return waitForHost(ctx, addr, interval, nil)
+}
+
+// WaitForBanner is like WaitForHost, but the host is only confirmed to be up
+// once the first line it sends on connecting matches banner. A host which
+// keeps sending another banner times out with an error matching
+// ErrBannerMismatch. A nil banner matches any host, as WaitForHost does.
+func WaitForBanner(ctx context.Context, addr string, interval time.Duration, banner *regexp.Regexp) error {
+	return waitForHost(ctx, addr, interval, banner)
+}
+
+func waitForHost(ctx context.Context, addr string, interval time.Duration, banner *regexp.Regexp) error {
+	dialer := net.Dialer{Timeout: interval}
+	var bannerErr error
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			if banner == nil {
+				return conn.Close()
+			}
+			bannerErr = checkBanner(conn, addr, interval, banner)
+			conn.Close()
+			if bannerErr == nil {
+				return nil
+			}
+		}
+
+		if err := sleepContext(ctx, interval); err != nil {
+			if bannerErr != nil {
+				err = bannerErr
+			}
+			return &NotUpError{Addr: addr, Err: err}
+		}
+	}
+}
+
+// checkBanner reads the first line conn sends, waiting up to timeout, and
+// matches it against banner.
+func checkBanner(conn net.Conn, addr string, timeout time.Duration, banner *regexp.Regexp) error {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	// A line longer than the buffer is matched as far as it was read.
+	line, err := bufio.NewReaderSize(conn, 1024).ReadSlice('\n')
+	if len(line) == 0 && err != nil {
+		return newError(ErrBannerMismatch, err, "no banner from %s: %s", addr, err)
+	}
+	line = bytes.TrimRight(line, "\r\n")
+	if !banner.Match(line) {
+		return newError(ErrBannerMismatch, nil, "banner %q from %s doesn't match %s", line, addr, banner)
+	}
+	return nil
+}
+
+// WakeUntilUp sends a magic packet to mac and waits for ip to accept TCP
+// connections, resending with exponential backoff up to maxAttempts times. It
+// returns nil as soon as the host answers.
+func WakeUntilUp(mac, ip string, maxAttempts int, opts ...Option) error {
+	return WakeUntilUpContext(context.Background(), mac, ip, maxAttempts, opts...)
+}
+
+// WakeUntilUpContext is like WakeUntilUp, but gives up once ctx is done.
+func WakeUntilUpContext(ctx context.Context, mac, ip string, maxAttempts int, opts ...Option) error {
+	o := newOptions(opts)
+	if maxAttempts < 1 {
+		return fmt.Errorf("max attempts %d must be at least 1", maxAttempts)
+	}
+	if o.waitPort < 1 || o.waitPort > 65535 {
+		return fmt.Errorf("wait port %d is out of range (expected 1-65535)", o.waitPort)
+	}
+	if o.retryDelay <= 0 {
+		return fmt.Errorf("retry delay %s must be positive", o.retryDelay)
+	}
+
+	mp, err := o.packet(mac)
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(ip, strconv.Itoa(o.waitPort))
+
+	delay := o.retryDelay
+	for attempt := 1; ; attempt++ {
+		if _, _, err := Send(ctx, mp, opts...); err != nil {
+			return err
+		}
+		o.logf("attempt %d of %d: waiting up to %s for %s", attempt, maxAttempts, delay, addr)
+
+		waitCtx, cancel := context.WithTimeout(ctx, delay)
+		err := waitForHost(waitCtx, addr, time.Second, o.banner)
+		cancel()
+		switch {
+		case err == nil:
+			return nil
+		case ctx.Err() != nil:
+			return err
+		case attempt >= maxAttempts:
+			return &NotUpError{Addr: addr, Attempts: maxAttempts, Err: err}
+		}
+		delay *= 2
+	}
+}
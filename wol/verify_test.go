@@ -0,0 +1,82 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestWakeUntilUp(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	opts := []Option{
+		WithBroadcast("127.0.0.1"),
+		WithWaitPort(port),
+		WithRetryDelay(100 * time.Millisecond),
+	}
+
+	if err := WakeUntilUp("18:18:18:18:18:18", "127.0.0.1", 1, opts...); err != nil {
+		t.Errorf("WakeUntilUp with a listening host failed: %s", err)
+	}
+
+	// Nothing answers on the port once the listener is closed.
+	ln.Close()
+	start := time.Now()
+	err = WakeUntilUp("18:18:18:18:18:18", "127.0.0.1", 2, opts...)
+	var notUp *NotUpError
+	if !errors.As(err, &notUp) || notUp.Attempts != 2 {
+		t.Fatalf("WakeUntilUp with nothing on port %d = %v, want a NotUpError after 2 attempts", port, err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("WakeUntilUp gave up after %s, want at least 100ms + 200ms of backoff", elapsed)
+	}
+
+	for _, maxAttempts := range []int{0, -1} {
+		if err := WakeUntilUp("18:18:18:18:18:18", "127.0.0.1", maxAttempts, opts...); err == nil {
+			t.Errorf("WakeUntilUp with %d attempts succeeded, want error", maxAttempts)
+		}
+	}
+}
+
+func TestWaitForBanner(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			conn.Close()
+		}
+	}()
+	addr := ln.Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := WaitForBanner(ctx, addr, 100*time.Millisecond, regexp.MustCompile(`^SSH-2\.0-OpenSSH`)); err != nil {
+		t.Errorf("WaitForBanner with a matching banner failed: %s", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err = WaitForBanner(ctx, addr, 100*time.Millisecond, regexp.MustCompile(`dropbear`))
+	var notUp *NotUpError
+	if !errors.As(err, &notUp) || !errors.Is(err, ErrBannerMismatch) {
+		t.Errorf("WaitForBanner with another banner = %v, want a NotUpError matching ErrBannerMismatch", err)
+	}
+}
@@ -0,0 +1,712 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	// DefaultBroadcast is the limited broadcast address magic packets are sent
+	// to when no other destination is configured.
+	DefaultBroadcast = "255.255.255.255"
+
+	// DefaultMulticast6 is the IPv6 all-nodes link-local multicast group. IPv6
+	// has no broadcast address, so this takes the place of DefaultBroadcast
+	// when sending over "udp6".
+	DefaultMulticast6 = "ff02::1"
+
+	// DefaultPort is the conventional Wake-on-LAN UDP port.
+	DefaultPort = 9
+
+	// DefaultWriteTimeout bounds each write of a magic packet, so that a hung
+	// socket can't block a send forever.
+	DefaultWriteTimeout = 5 * time.Second
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Option configures how Wake sends a magic packet.
+type Option func(*options)
+
+type options struct {
+	network    string
+	broadcast  string
+	port       int
+	iface      string
+	count      int
+	interval   time.Duration
+	jitter     float64
+	logger     *log.Logger
+	sourcePort int
+	waitPort   int
+	retryDelay time.Duration
+	banner     *regexp.Regexp
+
+	writeTimeout time.Duration
+	password     string
+	noResolve    bool
+	addrCache    *AddrCache
+	throttle     func(ctx context.Context) error
+	onConnect    func(local, remote net.Addr)
+	onSent       func(n int)
+
+	resolveRetries    int
+	resolveRetryDelay time.Duration
+}
+
+// newOptions applies opts over the defaults.
+func newOptions(opts []Option) options {
+	o := options{
+		network:    "udp",
+		port:       DefaultPort,
+		count:      1,
+		waitPort:   DefaultWaitPort,
+		retryDelay: DefaultRetryDelay,
+
+		writeTimeout: DefaultWriteTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// validate checks the options which apply however the packets are sent.
+func (o *options) validate() error {
+	if o.count < 1 {
+		return fmt.Errorf("count %d must be at least 1", o.count)
+	}
+	if o.jitter < 0 || o.jitter > 1 {
+		return fmt.Errorf("jitter %g is out of range (expected 0-1)", o.jitter)
+	}
+	return nil
+}
+
+// expected returns the number of bytes sending a packet of size bytes
+// WithCount times writes.
+func (o *options) expected(size int) int {
+	return size * o.count
+}
+
+// packet builds the magic packet for mac, with the WithPassword if any.
+func (o *options) packet(mac string) (*MagicPacket, error) {
+	if o.password != "" {
+		return MagicPacketNewWithPassword(mac, o.password)
+	}
+	return MagicPacketNew(mac)
+}
+
+// logf logs a step of sending the magic packet, if WithLogger was given.
+func (o *options) logf(format string, args ...interface{}) {
+	if o.logger != nil {
+		o.logger.Printf(format, args...)
+	}
+}
+
+// WithNetwork selects the transport and address family used to send the magic
+// packet: "udp" (the default), "udp4" or "udp6", or "tcp", "tcp4" or "tcp6".
+// The magic packet itself is identical for every network, only the transport
+// differs. TCP can't broadcast, so it needs a WithBroadcast host, typically a
+// relay which rebroadcasts the bytes on its own LAN.
+func WithNetwork(network string) Option {
+	return func(o *options) {
+		o.network = network
+	}
+}
+
+// WithBroadcast sets the IP address (or hostname) the magic packet is sent to.
+// It defaults to DefaultBroadcast, or DefaultMulticast6 over "udp6". A hostname
+// is resolved on every send, so that a dynamic DNS name of a router forwarding
+// the port to its LAN's broadcast address can wake machines over the internet.
+func WithBroadcast(ip string) Option {
+	return func(o *options) {
+		o.broadcast = ip
+	}
+}
+
+// WithPort sets the destination UDP port, which must be in the range 1-65535.
+func WithPort(port int) Option {
+	return func(o *options) {
+		o.port = port
+	}
+}
+
+// WithInterface binds the sending socket to the first IPv4 address of a network
+// interface, selected by its name ("eth0", or "Ethernet 2" on Windows), its
+// index, or one of its addresses or subnets ("192.168.1.20", "192.168.1.0/24").
+// Over "udp6" the interface is instead used as the zone of a link-local
+// destination such as ff02::1.
+func WithInterface(iface string) Option {
+	return func(o *options) {
+		o.iface = iface
+	}
+}
+
+// WithSourcePort sends the magic packet from a fixed local UDP port instead of
+// an ephemeral one, so that NAT mappings and firewall rules along the way stay
+// stable between wakes.
+func WithSourcePort(port int) Option {
+	return func(o *options) {
+		o.sourcePort = port
+	}
+}
+
+// WithCount sends the same magic packet count times, to make up for packets
+// dropped on unreliable networks. It defaults to 1.
+func WithCount(count int) Option {
+	return func(o *options) {
+		o.count = count
+	}
+}
+
+// WithInterval sets the delay between repeated sends when WithCount is
+// greater than 1.
+func WithInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.interval = interval
+	}
+}
+
+// WithJitter randomizes each WithInterval by up to plus or minus fraction of
+// it, e.g. 0.2 for 20%, so that repeated sends don't keep lining up with a
+// switch's processing cycle. It defaults to 0, a fixed interval.
+func WithJitter(fraction float64) Option {
+	return func(o *options) {
+		o.jitter = fraction
+	}
+}
+
+// WithWriteTimeout sets how long each write of the magic packet may block
+// before failing with ErrWriteTimeout, DefaultWriteTimeout unless given. A
+// timeout of 0 leaves only the context to give up on a hung write.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = timeout
+	}
+}
+
+// WithNoResolve requires the WithBroadcast destination to be a literal IP
+// address, guaranteeing that no name resolution takes place. A hostname fails
+// instead of being looked up.
+func WithNoResolve() Option {
+	return func(o *options) {
+		o.noResolve = true
+	}
+}
+
+// WithAddrCache looks destinations up in cache before resolving them, and
+// remembers what they resolved to there, so that a batch of sends to the same
+// hostname resolves it only once.
+func WithAddrCache(cache *AddrCache) Option {
+	return func(o *options) {
+		o.addrCache = cache
+	}
+}
+
+// WithThrottle calls throttle before writing each magic packet, which may
+// block to pace the sends, e.g. to share a rate limit between many wakes. An
+// error from throttle abandons the remaining packets.
+func WithThrottle(throttle func(ctx context.Context) error) Option {
+	return func(o *options) {
+		o.throttle = throttle
+	}
+}
+
+// WithOnConnect calls fn with the local and remote address of the connection
+// once it is open, before anything is written, to log or check which local
+// address (and so which network interface) the magic packet leaves from.
+func WithOnConnect(fn func(local, remote net.Addr)) Option {
+	return func(o *options) {
+		o.onConnect = fn
+	}
+}
+
+// WithOnSent calls fn with the number of bytes written every time a magic
+// packet (with all of its WithCount repeats) has been sent, even partly, e.g.
+// to count the bytes sent by WakeUntilUp across its attempts.
+func WithOnSent(fn func(n int)) Option {
+	return func(o *options) {
+		o.onSent = fn
+	}
+}
+
+// WithResolveRetries retries resolving a destination hostname up to retries
+// more times, delay apart, when the lookup fails for a reason which may pass,
+// such as a resolver timing out. A name which doesn't exist fails at once.
+func WithResolveRetries(retries int, delay time.Duration) Option {
+	return func(o *options) {
+		o.resolveRetries = retries
+		o.resolveRetryDelay = delay
+	}
+}
+
+// WithPassword adds a SecureOn password such as "01:02:03:04:05:06" or
+// "ascii:secret" to the magic packets built from a mac address string, by
+// Wake, Waker.Wake and WakeUntilUp. A packet passed to Send already carries
+// its own password.
+func WithPassword(password string) Option {
+	return func(o *options) {
+		o.password = password
+	}
+}
+
+// WithLogger logs each step of sending the magic packet to logger: the local
+// address bound, the resolved destination and the bytes written.
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Wake builds a magic packet for the given mac address and sends it over UDP.
+func Wake(mac string, opts ...Option) error {
+	return WakeContext(context.Background(), mac, opts...)
+}
+
+// WakeContext is like Wake, but gives up on resolving the destination, dialing
+// and writing the magic packet once ctx is done.
+func WakeContext(ctx context.Context, mac string, opts ...Option) error {
+	o := newOptions(opts)
+	mp, err := o.packet(mac)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = Send(ctx, mp, opts...)
+	return err
+}
+
+// Send writes an already built magic packet over UDP (or TCP, see WithNetwork),
+// returning the number of bytes written across every repeat alongside the
+// number expected, i.e. the packet size times the count set by WithCount. An
+// error is returned unless every packet was written in full, but n still
+// counts the bytes which did go out.
+func Send(ctx context.Context, mp *MagicPacket, opts ...Option) (n, expected int, err error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return 0, 0, err
+	}
+
+	// Grab a stream of bytes to send.
+	bs, err := mp.Marshal()
+	if err != nil {
+		return 0, 0, err
+	}
+	return sendBytes(ctx, bs, mp.Size(), o)
+}
+
+// SendBytes writes bs as is over UDP (or TCP, see WithNetwork), like Send but
+// without checking that it is a magic packet, e.g. to replay a packet saved
+// earlier.
+func SendBytes(ctx context.Context, bs []byte, opts ...Option) (n, expected int, err error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return 0, 0, err
+	}
+	return sendBytes(ctx, bs, len(bs), o)
+}
+
+// sendBytes dials the destination and writes bs WithCount times, expecting
+// each write to take size bytes.
+func sendBytes(ctx context.Context, bs []byte, size int, o options) (n, expected int, err error) {
+	expected = o.expected(size)
+
+	conn, err := dial(ctx, &o)
+	if err != nil {
+		return 0, expected, err
+	}
+	defer conn.Close()
+
+	n, err = writePackets(ctx, &deadlineConn{conn, ctx, o.writeTimeout}, bs, o)
+	return n, expected, err
+}
+
+// deadlineConn sets a write deadline before every write to a connection: the
+// write timeout from now, or the context's deadline if that comes first.
+type deadlineConn struct {
+	net.Conn
+	ctx     context.Context
+	timeout time.Duration
+}
+
+func (c *deadlineConn) Write(bs []byte) (int, error) {
+	var deadline time.Time
+	if c.timeout > 0 {
+		deadline = time.Now().Add(c.timeout)
+	}
+	if ctxDeadline, ok := c.ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	if err := c.Conn.SetWriteDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	n, err := c.Conn.Write(bs)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = newError(ErrWriteTimeout, err, "writing the magic packet to %s timed out", c.Conn.RemoteAddr())
+	}
+	return n, err
+}
+
+// dial validates the destination options and opens a connection to it, filling
+// in the default broadcast address.
+func dial(ctx context.Context, o *options) (net.Conn, error) {
+	isTCP := strings.HasPrefix(o.network, "tcp")
+	switch o.network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("unsupported network %q (expected udp, udp4, udp6, tcp, tcp4 or tcp6)", o.network)
+	}
+	if o.broadcast == "" && isTCP {
+		return nil, fmt.Errorf("%s needs a destination host, there is no TCP broadcast", o.network)
+	}
+
+	// Populate the local address in the event that the broadcast interface has
+	// been set, otherwise let the OS pick the default interface.
+	var dialer net.Dialer
+	localAddr, err := o.localAddr()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case localAddr != nil && isTCP:
+		dialer.LocalAddr = &net.TCPAddr{IP: localAddr.IP, Port: localAddr.Port}
+	case localAddr != nil:
+		dialer.LocalAddr = localAddr
+	}
+
+	udpAddr, err := o.destination(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some OSes refuse to send to a broadcast address unless SO_BROADCAST is
+	// set, rather than trusting net to set it.
+	if !isTCP && isBroadcastAddr(udpAddr.IP) {
+		dialer.Control = broadcastControl
+	}
+
+	// Grab a connection to send our packet of bytes.
+	conn, err := dialer.DialContext(ctx, o.network, udpAddr.String())
+	if err != nil {
+		return nil, newError(ErrDialFailed, err, "%s", err)
+	}
+	o.logf("sending from %s to %s", conn.LocalAddr(), conn.RemoteAddr())
+	if o.onConnect != nil {
+		o.onConnect(conn.LocalAddr(), conn.RemoteAddr())
+	}
+	return conn, nil
+}
+
+// localAddr returns the address to send from, as picked by WithInterface and
+// WithSourcePort, or nil to let the OS pick.
+func (o *options) localAddr() (*net.UDPAddr, error) {
+	if o.sourcePort < 0 || o.sourcePort > 65535 {
+		return nil, fmt.Errorf("source port %d is out of range (expected 0-65535)", o.sourcePort)
+	}
+	if o.iface != "" && !strings.HasSuffix(o.network, "6") {
+		localAddr, _, err := ipFromInterface(o.iface)
+		if err != nil {
+			return nil, err
+		}
+		localAddr.Port = o.sourcePort
+		o.logf("interface %s: binding local address %s", o.iface, localAddr)
+		return localAddr, nil
+	}
+	if o.sourcePort != 0 {
+		o.logf("binding local port %d", o.sourcePort)
+		return &net.UDPAddr{Port: o.sourcePort}, nil
+	}
+	return nil, nil
+}
+
+// destination validates the destination options and resolves them to the
+// address to send to, filling in the default broadcast address.
+func (o *options) destination(ctx context.Context) (*net.UDPAddr, error) {
+	isIPv6 := strings.HasSuffix(o.network, "6")
+	switch {
+	case o.broadcast == "" && isIPv6:
+		o.broadcast = DefaultMulticast6
+	case o.broadcast == "":
+		o.broadcast = DefaultBroadcast
+	}
+
+	if o.port < 1 || o.port > 65535 {
+		return nil, fmt.Errorf("port %d is out of range (expected 1-65535)", o.port)
+	}
+
+	var udpAddr *net.UDPAddr
+	var err error
+	if o.noResolve {
+		if udpAddr, err = parseUDPAddr(o.broadcast, o.port); err != nil {
+			return nil, err
+		}
+	} else if udpAddr = o.addrCache.lookup(o.network, o.broadcast, o.port); udpAddr != nil {
+		o.logf("reusing %s address %s resolved for %s", o.network, udpAddr, o.broadcast)
+	} else {
+		if udpAddr, err = o.resolve(ctx); err != nil {
+			return nil, newError(ErrResolveFailed, err, "resolving broadcast address: %s", err)
+		}
+		o.logf("resolved %s to %s address %s", o.broadcast, o.network, udpAddr)
+		o.addrCache.store(o.network, o.broadcast, o.port, udpAddr)
+	}
+
+	// A loopback unicast address is left alone, as it's handy for testing with
+	// a local receiver, but the loopback broadcast is always a mistake.
+	if isLoopbackBroadcast(udpAddr.IP) {
+		return nil, newError(ErrLoopback, nil, "%s is the loopback broadcast address, which never reaches another machine; send to the broadcast address of the target's network, e.g. 192.168.1.255", udpAddr.IP)
+	}
+
+	// Link-local IPv6 destinations are only meaningful on a given interface.
+	if isIPv6 && o.iface != "" && udpAddr.Zone == "" &&
+		(udpAddr.IP.IsLinkLocalMulticast() || udpAddr.IP.IsLinkLocalUnicast()) {
+		udpAddr.Zone = o.iface
+	}
+	return udpAddr, nil
+}
+
+// isLoopbackBroadcast reports whether ip is the broadcast address of the IPv4
+// loopback network, 127.255.255.255.
+func isLoopbackBroadcast(ip net.IP) bool {
+	return ip.Equal(net.IPv4(127, 255, 255, 255))
+}
+
+// broadcastControl is a net.Dialer Control function enabling SO_BROADCAST on
+// the socket before it connects.
+func broadcastControl(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = setBroadcast(fd)
+	})
+	if err != nil {
+		return err
+	}
+	if sockErr != nil {
+		return fmt.Errorf("enabling broadcast on the socket: %w", sockErr)
+	}
+	return nil
+}
+
+// SendTo is like Send, but writes the magic packet to w instead of dialing a
+// UDP connection itself, e.g. to a connection set up by the caller or to a
+// fake in tests. The destination options are ignored.
+func SendTo(ctx context.Context, w io.Writer, mp *MagicPacket, opts ...Option) (n, expected int, err error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return 0, 0, err
+	}
+
+	bs, err := mp.Marshal()
+	if err != nil {
+		return 0, 0, err
+	}
+	expected = o.expected(mp.Size())
+
+	n, err = writePackets(ctx, w, bs, o)
+	return n, expected, err
+}
+
+// writePackets writes bs to w WithCount times, returning the total number of
+// bytes written.
+func writePackets(ctx context.Context, w io.Writer, bs []byte, o options) (int, error) {
+	// Repeated sends reuse the same bytes and connection, the first failure is
+	// remembered but doesn't stop the remaining attempts.
+	var firstErr error
+	n, sent := 0, 0
+	for idx := 0; idx < o.count; idx++ {
+		if idx > 0 && o.interval > 0 {
+			if err := sleepContext(ctx, o.jittered(o.interval)); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				break
+			}
+		}
+
+		if o.throttle != nil {
+			if err := o.throttle(ctx); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				break
+			}
+		}
+
+		written, err := w.Write(bs)
+		o.logf("packet %d of %d: wrote %d of %d bytes", idx+1, o.count, written, len(bs))
+		n += written
+		if err == nil && written != len(bs) {
+			err = &ShortWriteError{Written: written, Expected: len(bs)}
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		sent++
+	}
+	if o.onSent != nil {
+		o.onSent(n)
+	}
+
+	if firstErr != nil && o.count > 1 {
+		return n, fmt.Errorf("only %d of %d magic packets sent: %w", sent, o.count, firstErr)
+	}
+	return n, firstErr
+}
+
+// resolveUDPAddr looks up host using the resolver, so that the lookup can be
+// abandoned with ctx. Like net.ResolveUDPAddr, "udp" (or "tcp") prefers an IPv4
+// address.
+func resolveUDPAddr(ctx context.Context, network, host string, port int) (*net.UDPAddr, error) {
+	ipAddrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var match *net.IPAddr
+	for idx := range ipAddrs {
+		isIPv4 := ipAddrs[idx].IP.To4() != nil
+		if (strings.HasSuffix(network, "4") && !isIPv4) || (strings.HasSuffix(network, "6") && isIPv4) {
+			continue
+		}
+		if match == nil || (isIPv4 && match.IP.To4() == nil) {
+			match = &ipAddrs[idx]
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no %s address found for %s", network, host)
+	}
+
+	return &net.UDPAddr{
+		IP:   match.IP,
+		Port: port,
+		Zone: match.Zone,
+	}, nil
+}
+
+// resolve resolves the destination, retrying transient failures as allowed
+// by WithResolveRetries.
+func (o *options) resolve(ctx context.Context) (*net.UDPAddr, error) {
+	for attempt := 0; ; attempt++ {
+		udpAddr, err := resolveUDPAddr(ctx, o.network, o.broadcast, o.port)
+		if err == nil || attempt >= o.resolveRetries || !isTransientDNSError(err) {
+			return udpAddr, err
+		}
+		o.logf("resolving %s failed, retrying in %s: %s", o.broadcast, o.resolveRetryDelay, err)
+		if err := sleepContext(ctx, o.resolveRetryDelay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isTransientDNSError reports whether err is a failed lookup which may succeed
+// when tried again, unlike a name which doesn't exist (NXDOMAIN).
+func isTransientDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && !dnsErr.IsNotFound
+}
+
+// AddrCache remembers the addresses destinations resolved to, see
+// WithAddrCache. The zero value is an empty cache, which is safe for
+// concurrent use.
+type AddrCache struct {
+	mu    sync.Mutex
+	addrs map[string]net.UDPAddr
+}
+
+// lookup returns a copy of the address cached for host, or nil if there is
+// none. A nil cache never has one.
+func (c *AddrCache) lookup(network, host string, port int) *net.UDPAddr {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	addr, ok := c.addrs[cacheKey(network, host, port)]
+	if !ok {
+		return nil
+	}
+	return &addr
+}
+
+// store caches the address host resolved to, unless the cache is nil.
+func (c *AddrCache) store(network, host string, port int, addr *net.UDPAddr) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.addrs == nil {
+		c.addrs = map[string]net.UDPAddr{}
+	}
+	c.addrs[cacheKey(network, host, port)] = *addr
+}
+
+// cacheKey identifies a destination in an AddrCache.
+func cacheKey(network, host string, port int) string {
+	return network + " " + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// parseUDPAddr parses host strictly as an IP address, with an optional IPv6
+// zone such as "fe80::1%eth0", without ever resolving it as a name.
+func parseUDPAddr(host string, port int) (*net.UDPAddr, error) {
+	addr, zone, _ := strings.Cut(host, "%")
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("%s is not an IP address, and name resolution is disabled", host)
+	}
+	return &net.UDPAddr{IP: ip, Port: port, Zone: zone}, nil
+}
+
+// jitterRand picks the WithJitter offsets. It's seeded per process, so that
+// runs started together don't pick the same ones.
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// jittered returns interval randomized by the WithJitter fraction.
+func (o *options) jittered(interval time.Duration) time.Duration {
+	if o.jitter == 0 {
+		return interval
+	}
+	jitterRand.Lock()
+	offset := (2*jitterRand.Float64() - 1) * o.jitter
+	jitterRand.Unlock()
+	return time.Duration(float64(interval) * (1 + offset))
+}
+
+// sleepContext pauses for d, returning early with an error if ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
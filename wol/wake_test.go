@@ -0,0 +1,445 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// listenLoopback returns a UDP socket on a free loopback port for the packets
+// under test, closed at the end of the test, and its port.
+func listenLoopback(t *testing.T) (*net.UDPConn, int) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// readPacket returns the next datagram received on conn and its sender,
+// failing the test if none arrives within a second.
+func readPacket(t *testing.T, conn *net.UDPConn) ([]byte, *net.UDPAddr) {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 256)
+	n, from, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("no packet received on %s: %s", conn.LocalAddr(), err)
+	}
+	return buf[:n], from
+}
+
+// testPacket returns the magic packet waking 18:18:18:18:18:18.
+func testPacket(t *testing.T) *MagicPacket {
+	t.Helper()
+	mp, err := MagicPacketNew("18:18:18:18:18:18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mp
+}
+
+func TestSend(t *testing.T) {
+	for _, tc := range []struct {
+		password string
+		count    int
+		size     int
+	}{
+		{count: 1, size: 102},
+		{password: "01:02:03:04", count: 1, size: 106},
+		{password: "01:02:03:04:05:06", count: 2, size: 108},
+	} {
+		conn, port := listenLoopback(t)
+
+		mp, err := MagicPacketNew("18:18:18:18:18:18")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.password != "" {
+			mp, err = MagicPacketNewWithPassword("18:18:18:18:18:18", tc.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := mp.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, expected, err := Send(context.Background(), mp,
+			WithBroadcast("127.0.0.1"), WithPort(port), WithCount(tc.count))
+		if err != nil {
+			t.Errorf("Send(%q) failed: %s", tc.password, err)
+			continue
+		}
+		if n != tc.size*tc.count || expected != tc.size*tc.count {
+			t.Errorf("Send(%q) = %d, %d bytes, want %d", tc.password, n, expected, tc.size*tc.count)
+		}
+
+		for idx := 0; idx < tc.count; idx++ {
+			if got, _ := readPacket(t, conn); !bytes.Equal(got, want) {
+				t.Errorf("Send(%q) packet %d = %x, want %x", tc.password, idx, got, want)
+			}
+		}
+	}
+}
+
+func TestSendBytes(t *testing.T) {
+	conn, port := listenLoopback(t)
+
+	want := []byte("not a magic packet")
+	n, expected, err := SendBytes(context.Background(), want,
+		WithBroadcast("127.0.0.1"), WithPort(port))
+	if err != nil {
+		t.Fatalf("SendBytes failed: %s", err)
+	}
+	if n != len(want) || expected != len(want) {
+		t.Errorf("SendBytes = %d, %d bytes, want %d", n, expected, len(want))
+	}
+
+	if got, _ := readPacket(t, conn); !bytes.Equal(got, want) {
+		t.Errorf("SendBytes packet = %q, want %q", got, want)
+	}
+}
+
+// shortWriter accepts at most limit bytes per Write, failing once err is set.
+type shortWriter struct {
+	bytes.Buffer
+	limit int
+	err   error
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.limit > 0 && len(p) > w.limit {
+		p = p[:w.limit]
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestSendTo(t *testing.T) {
+	mp := testPacket(t)
+	want, err := mp.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, expected, err := SendTo(context.Background(), &buf, mp, WithCount(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 306 || expected != 306 {
+		t.Errorf("SendTo = %d, %d bytes, want 306", n, expected)
+	}
+	if !bytes.Equal(buf.Bytes(), bytes.Repeat(want, 3)) {
+		t.Errorf("SendTo wrote %x, want %x three times", buf.Bytes(), want)
+	}
+
+	short := &shortWriter{limit: 100}
+	n, expected, err = SendTo(context.Background(), short, mp)
+	var shortErr *ShortWriteError
+	if !errors.As(err, &shortErr) || !errors.Is(err, ErrShortWrite) {
+		t.Errorf("SendTo with a short write = %v, want a ShortWriteError", err)
+	} else if shortErr.Written != 100 || shortErr.Expected != 102 {
+		t.Errorf("SendTo with a short write = %+v, want 100 of 102 bytes written", shortErr)
+	}
+	if n != 100 || expected != 102 {
+		t.Errorf("SendTo with a short write = %d, %d bytes, want 100, 102", n, expected)
+	}
+
+	failing := &shortWriter{err: errors.New("network is down")}
+	if _, _, err := SendTo(context.Background(), failing, mp, WithCount(2)); !errors.Is(err, failing.err) {
+		t.Errorf("SendTo with a failing writer = %v, want %v", err, failing.err)
+	}
+}
+
+func TestWaker(t *testing.T) {
+	conn, _ := listenLoopback(t)
+
+	waker, err := NewWaker(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mac := range []string{"18:18:18:18:18:18", "18-18-18-18-18-19"} {
+		if err := waker.Wake(mac); err != nil {
+			t.Fatalf("Waker.Wake(%q) failed: %s", mac, err)
+		}
+		got, _ := readPacket(t, conn)
+		mp, err := MagicPacketUnmarshal(got)
+		if err != nil {
+			t.Fatalf("Waker.Wake(%q) sent an invalid packet: %s", mac, err)
+		}
+		if want, _ := NormalizeMAC(mac); mp.MAC() != want {
+			t.Errorf("Waker.Wake(%q) woke %s, want %s", mac, mp.MAC(), want)
+		}
+	}
+
+	if err := waker.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waker.Wake("18:18:18:18:18:18"); err == nil {
+		t.Errorf("Waker.Wake after Close succeeded, want error")
+	}
+}
+
+func TestWakerInvalidOptions(t *testing.T) {
+	if _, err := NewWaker("127.0.0.1:9", WithCount(0)); err == nil {
+		t.Error("NewWaker WithCount(0) succeeded, want an error")
+	}
+	if _, err := NewSharedWaker(WithJitter(2)); err == nil {
+		t.Error("NewSharedWaker WithJitter(2) succeeded, want an error")
+	}
+}
+
+func TestSharedWaker(t *testing.T) {
+	var listeners []*net.UDPConn
+	for idx := 0; idx < 2; idx++ {
+		conn, _ := listenLoopback(t)
+		listeners = append(listeners, conn)
+	}
+
+	waker, err := NewSharedWaker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer waker.Close()
+
+	// Every destination receives its packet from the one shared socket.
+	var sources []string
+	for _, conn := range listeners {
+		if err := waker.WakeTo("18:18:18:18:18:18", conn.LocalAddr().String()); err != nil {
+			t.Fatalf("Waker.WakeTo(%s) failed: %s", conn.LocalAddr(), err)
+		}
+		got, from := readPacket(t, conn)
+		if _, err := MagicPacketUnmarshal(got); err != nil {
+			t.Fatalf("Waker.WakeTo(%s) sent an invalid packet: %s", conn.LocalAddr(), err)
+		}
+		sources = append(sources, from.String())
+	}
+	if sources[0] != sources[1] {
+		t.Errorf("Waker.WakeTo sent from %s and %s, want a single socket", sources[0], sources[1])
+	}
+
+	connected, err := NewWaker(listeners[0].LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connected.Close()
+	if err := connected.WakeTo("18:18:18:18:18:18", listeners[1].LocalAddr().String()); err == nil {
+		t.Error("WakeTo on a connected Waker succeeded, want an error")
+	}
+}
+
+func TestWakeWithPassword(t *testing.T) {
+	conn, port := listenLoopback(t)
+
+	err := Wake("18:18:18:18:18:18", WithBroadcast("127.0.0.1"),
+		WithPort(port), WithPassword("01:02:03:04"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := readPacket(t, conn)
+	mp, err := MagicPacketUnmarshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{1, 2, 3, 4}; !bytes.Equal(mp.Password(), want) {
+		t.Errorf("Wake WithPassword sent password % X, want % X", mp.Password(), want)
+	}
+
+	if err := Wake("18:18:18:18:18:18", WithPassword("01:02")); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Wake WithPassword(\"01:02\") = %v, want ErrInvalidPassword", err)
+	}
+}
+
+func TestSendSourcePort(t *testing.T) {
+	conn, port := listenLoopback(t)
+
+	// Borrow a free port for the sender.
+	free, sourcePort := listenLoopback(t)
+	free.Close()
+
+	_, _, err := Send(context.Background(), testPacket(t), WithBroadcast("127.0.0.1"),
+		WithPort(port), WithSourcePort(sourcePort))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, from := readPacket(t, conn); from.Port != sourcePort {
+		t.Errorf("Send with WithSourcePort(%d) sent from port %d", sourcePort, from.Port)
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	// Nothing ever reads the other end of the pipe, so writes block.
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	mp := testPacket(t)
+	w := &deadlineConn{conn, context.Background(), 10 * time.Millisecond}
+	if _, _, err := SendTo(context.Background(), w, mp); !errors.Is(err, ErrWriteTimeout) {
+		t.Errorf("SendTo a blocked connection = %v, want ErrWriteTimeout", err)
+	}
+}
+
+func TestSendNoResolve(t *testing.T) {
+	mp := testPacket(t)
+	if _, _, err := Send(context.Background(), mp, WithBroadcast("localhost"), WithNoResolve()); err == nil {
+		t.Error("Send to localhost WithNoResolve succeeded, want an error")
+	}
+	if _, _, err := Send(context.Background(), mp, WithBroadcast("127.0.0.1"), WithNoResolve()); err != nil {
+		t.Errorf("Send to 127.0.0.1 WithNoResolve failed: %s", err)
+	}
+}
+
+func TestSendLoopback(t *testing.T) {
+	mp := testPacket(t)
+	if _, _, err := Send(context.Background(), mp, WithBroadcast("127.255.255.255")); !errors.Is(err, ErrLoopback) {
+		t.Errorf("Send to 127.255.255.255 = %v, want ErrLoopback", err)
+	}
+
+	iefs, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ief := range iefs {
+		if ief.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		if _, _, err := Send(context.Background(), mp, WithInterface(ief.Name)); !errors.Is(err, ErrLoopback) {
+			t.Errorf("Send WithInterface(%q) = %v, want ErrLoopback", ief.Name, err)
+		}
+	}
+}
+
+func TestSendAddrCache(t *testing.T) {
+	conn, port := listenLoopback(t)
+	mp := testPacket(t)
+
+	var cache AddrCache
+	if _, _, err := Send(context.Background(), mp, WithBroadcast("localhost"), WithPort(port), WithAddrCache(&cache)); err != nil {
+		t.Fatal(err)
+	}
+	if cache.lookup("udp", "localhost", port) == nil {
+		t.Error("Send WithAddrCache didn't cache the localhost address")
+	}
+
+	// A name which can't resolve is only reachable through the cache.
+	cache.store("udp", "wol.invalid", port, conn.LocalAddr().(*net.UDPAddr))
+	if _, _, err := Send(context.Background(), mp, WithBroadcast("wol.invalid"), WithPort(port), WithAddrCache(&cache)); err != nil {
+		t.Errorf("Send to a cached address failed: %s", err)
+	}
+}
+
+func TestSendToThrottle(t *testing.T) {
+	mp := testPacket(t)
+
+	calls := 0
+	throttle := func(ctx context.Context) error {
+		calls++
+		if calls > 2 {
+			return errors.New("throttled")
+		}
+		return nil
+	}
+	var buf bytes.Buffer
+	n, _, err := SendTo(context.Background(), &buf, mp, WithCount(3), WithInterval(0), WithThrottle(throttle))
+	if err == nil {
+		t.Error("SendTo with a failing throttle succeeded, want an error")
+	}
+	if calls != 3 || n != 2*102 {
+		t.Errorf("SendTo WithThrottle called it %d times and wrote %d bytes, want 3 and %d", calls, n, 2*102)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	o := newOptions([]Option{WithJitter(0.2)})
+	interval := 100 * time.Millisecond
+	seen := map[time.Duration]bool{}
+	for idx := 0; idx < 100; idx++ {
+		got := o.jittered(interval)
+		if got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("jittered(%s) with 20%% jitter = %s, want within 80ms-120ms", interval, got)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("jittered(%s) always returned %v, want varying intervals", interval, seen)
+	}
+	if noJitter := newOptions(nil); noJitter.jittered(interval) != interval {
+		t.Errorf("jittered(%s) without jitter = %s, want it unchanged", interval, noJitter.jittered(interval))
+	}
+
+	mp := testPacket(t)
+	for _, jitter := range []float64{-0.1, 1.5} {
+		if _, _, err := SendTo(context.Background(), io.Discard, mp, WithJitter(jitter)); err == nil {
+			t.Errorf("SendTo WithJitter(%g) succeeded, want an error", jitter)
+		}
+	}
+}
+
+func TestSendToOnSent(t *testing.T) {
+	mp := testPacket(t)
+	var got []int
+	var buf bytes.Buffer
+	for i := 0; i < 2; i++ {
+		if _, _, err := SendTo(context.Background(), &buf, mp, WithCount(3), WithInterval(0),
+			WithOnSent(func(n int) { got = append(got, n) })); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != 2 || got[0] != 3*102 || got[1] != 3*102 {
+		t.Errorf("WithOnSent got %v, want [306 306]", got)
+	}
+}
+
+func TestSendOnConnect(t *testing.T) {
+	conn, port := listenLoopback(t)
+
+	var local, remote net.Addr
+	_, _, err := Send(context.Background(), testPacket(t), WithBroadcast("127.0.0.1"), WithPort(port),
+		WithOnConnect(func(l, r net.Addr) { local, remote = l, r }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local == nil || !local.(*net.UDPAddr).IP.IsLoopback() {
+		t.Errorf("WithOnConnect got local address %v, want a loopback address", local)
+	}
+	if remote == nil || remote.String() != conn.LocalAddr().String() {
+		t.Errorf("WithOnConnect got remote address %v, want %s", remote, conn.LocalAddr())
+	}
+}
+
+func TestIsTransientDNSError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{err: &net.DNSError{Err: "i/o timeout", Name: "relay.example.com", IsTimeout: true}, want: true},
+		{err: &net.DNSError{Err: "server misbehaving", Name: "relay.example.com", IsTemporary: true}, want: true},
+		{err: &net.DNSError{Err: "no such host", Name: "relay.example.com", IsNotFound: true}, want: false},
+		{err: errors.New("connection refused"), want: false},
+	} {
+		if got := isTransientDNSError(tc.err); got != tc.want {
+			t.Errorf("isTransientDNSError(%v) = %t, want %t", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package wol
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Waker sends magic packets over a single UDP connection which it keeps open,
+// saving the dial for every packet when the same destination is woken over and
+// over, e.g. by a monitoring or keep-alive script. A Waker from NewSharedWaker
+// instead keeps an unconnected socket open, which sends to the destination of
+// each WakeTo. A Waker is not safe for concurrent use.
+type Waker struct {
+	conn   net.Conn
+	shared *net.UDPConn
+	o      options
+}
+
+// NewWaker opens a UDP connection to broadcastAddr, either "host:port" or just
+// a host to use the WithPort. Options which pick the destination are
+// overridden by broadcastAddr.
+func NewWaker(broadcastAddr string, opts ...Option) (*Waker, error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	if err := o.setBroadcastAddr(broadcastAddr); err != nil {
+		return nil, err
+	}
+
+	conn, err := dial(context.Background(), &o)
+	if err != nil {
+		return nil, err
+	}
+	return &Waker{conn: conn, o: o}, nil
+}
+
+// NewSharedWaker opens a single unconnected UDP socket, bound to the
+// WithInterface and WithSourcePort address if given, which WakeTo sends to a
+// destination of its choosing. One socket thus serves targets on every subnet
+// of a multi-homed machine. It sends over IPv4 unless WithNetwork("udp6") is
+// given.
+func NewSharedWaker(opts ...Option) (*Waker, error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	switch o.network {
+	case "udp", "udp4":
+		o.network = "udp4"
+	case "udp6":
+	default:
+		return nil, fmt.Errorf("unsupported network %q for a shared Waker (expected udp, udp4 or udp6)", o.network)
+	}
+
+	localAddr, err := o.localAddr()
+	if err != nil {
+		return nil, err
+	}
+	var laddr string
+	if localAddr != nil {
+		laddr = localAddr.String()
+	}
+	var lc net.ListenConfig
+	if o.network == "udp4" {
+		lc.Control = broadcastControl
+	}
+	conn, err := lc.ListenPacket(context.Background(), o.network, laddr)
+	if err != nil {
+		return nil, newError(ErrDialFailed, err, "%s", err)
+	}
+	o.logf("sending from %s to each WakeTo destination", conn.LocalAddr())
+	return &Waker{shared: conn.(*net.UDPConn), o: o}, nil
+}
+
+// setBroadcastAddr sets the destination from addr, either "host:port" or just a
+// host. An empty addr keeps the WithBroadcast.
+func (o *options) setBroadcastAddr(addr string) error {
+	if addr == "" {
+		return nil
+	}
+	o.broadcast = addr
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		o.broadcast = host
+		if o.port, err = strconv.Atoi(port); err != nil {
+			return fmt.Errorf("%s has an invalid port %q", addr, port)
+		}
+	}
+	return nil
+}
+
+// Wake builds a magic packet for mac and writes it on the open connection, as
+// many times as WithCount asks for. A shared Waker sends it to the WithBroadcast
+// destination.
+func (w *Waker) Wake(mac string) error {
+	if w.shared != nil {
+		return w.WakeTo(mac, "")
+	}
+
+	mp, err := w.o.packet(mac)
+	if err != nil {
+		return err
+	}
+	bs, err := mp.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = writePackets(context.Background(), &deadlineConn{w.conn, context.Background(), w.o.writeTimeout}, bs, w.o)
+	return err
+}
+
+// WakeTo is like Wake, but sends the magic packet to broadcastAddr, either
+// "host:port" or just a host to use the WithPort. It needs a Waker from
+// NewSharedWaker, as the connection of any other Waker only reaches the
+// destination it was opened for.
+func (w *Waker) WakeTo(mac, broadcastAddr string) error {
+	if w.shared == nil {
+		return fmt.Errorf("WakeTo needs a shared Waker, this one is connected to %s", w.conn.RemoteAddr())
+	}
+
+	o := w.o
+	if err := o.setBroadcastAddr(broadcastAddr); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	dest, err := o.destination(ctx)
+	if err != nil {
+		return err
+	}
+	if o.onConnect != nil {
+		o.onConnect(w.shared.LocalAddr(), dest)
+	}
+
+	mp, err := o.packet(mac)
+	if err != nil {
+		return err
+	}
+	bs, err := mp.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = writePackets(ctx, &deadlineConn{&unconnectedConn{w.shared, dest}, ctx, o.writeTimeout}, bs, o)
+	return err
+}
+
+// Close closes the connection, after which Wake fails.
+func (w *Waker) Close() error {
+	if w.shared != nil {
+		return w.shared.Close()
+	}
+	return w.conn.Close()
+}
+
+// unconnectedConn writes to addr on an unconnected socket, so that it can be
+// used like a connection to addr.
+type unconnectedConn struct {
+	*net.UDPConn
+	addr *net.UDPAddr
+}
+
+func (c *unconnectedConn) Write(bs []byte) (int, error) {
+	return c.UDPConn.WriteToUDP(bs, c.addr)
+}
+
+func (c *unconnectedConn) RemoteAddr() net.Addr {
+	return c.addr
+}